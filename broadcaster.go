@@ -0,0 +1,163 @@
+package smartmeter
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// SubscriberPolicy decides what happens to a readout for a subscriber whose buffer is full.
+type SubscriberPolicy int
+
+const (
+	// DropWhenFull drops readouts for a subscriber while its buffer is full, so it can't delay other subscribers.
+	DropWhenFull SubscriberPolicy = iota
+	// BlockWhenFull waits until a subscriber has room in its buffer, which delays all other subscribers.
+	BlockWhenFull
+)
+
+// Broadcaster distributes readouts to any number of subscribers, e.g. storage, a live display and alerting.
+//
+// Example:
+//
+//	rChan := make(chan Readout)
+//	go ReadTelegrams(source, rChan)
+//	b := NewBroadcaster()
+//	live := b.Subscribe(1, DropWhenFull)
+//	store := b.Subscribe(100, BlockWhenFull)
+//	go b.Run(rChan)
+type Broadcaster struct {
+	// broadcastMutex delivers readouts one broadcast at a time, without blocking subscribing and closing.
+	broadcastMutex sync.Mutex
+	mutex          sync.Mutex
+	subscribers    map[*Subscription]struct{}
+	closed         bool
+}
+
+// Subscription receives the readouts of a Broadcaster on C until it is unsubscribed or the broadcaster is closed.
+type Subscription struct {
+	// C receives the readouts. It is closed when the subscription ends.
+	C <-chan Readout
+
+	c           chan Readout
+	policy      SubscriberPolicy
+	broadcaster *Broadcaster
+	done        chan struct{}
+	doneOnce    sync.Once
+	dropped     uint64
+	// mutex prevents closing c while a readout is sent on it.
+	mutex  sync.Mutex
+	closed bool
+}
+
+// NewBroadcaster creates a new Broadcaster without subscribers.
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{subscribers: make(map[*Subscription]struct{})}
+}
+
+// Subscribe registers a new subscriber with a buffer of the given size.
+// Subscribing to a closed broadcaster returns a subscription which has already ended.
+func (b *Broadcaster) Subscribe(buffer int, policy SubscriberPolicy) *Subscription {
+	c := make(chan Readout, buffer)
+	s := &Subscription{C: c, c: c, policy: policy, broadcaster: b, done: make(chan struct{})}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.closed {
+		s.close()
+		return s
+	}
+	b.subscribers[s] = struct{}{}
+	return s
+}
+
+// Broadcast sends the readout to all subscribers according to their policy.
+// Subscribers can subscribe, unsubscribe and close the broadcaster while it waits for a subscriber.
+func (b *Broadcaster) Broadcast(r Readout) {
+	b.broadcastMutex.Lock()
+	defer b.broadcastMutex.Unlock()
+
+	b.mutex.Lock()
+	subscribers := make([]*Subscription, 0, len(b.subscribers))
+	for s := range b.subscribers {
+		subscribers = append(subscribers, s)
+	}
+	b.mutex.Unlock()
+
+	for _, s := range subscribers {
+		s.send(r)
+	}
+}
+
+// Run broadcasts all readouts received on the given channel and closes the broadcaster once it is closed.
+func (b *Broadcaster) Run(rChan chan Readout) {
+	for r := range rChan {
+		b.Broadcast(r)
+	}
+	b.Close()
+}
+
+// Close ends all subscriptions. Readouts broadcast afterwards are discarded.
+func (b *Broadcaster) Close() {
+	b.mutex.Lock()
+	subscribers := b.subscribers
+	b.subscribers = make(map[*Subscription]struct{})
+	b.closed = true
+	b.mutex.Unlock()
+
+	for s := range subscribers {
+		s.close()
+	}
+}
+
+// Unsubscribe ends the subscription and closes C. It is safe to call while a broadcast is blocked on this subscriber.
+func (s *Subscription) Unsubscribe() {
+	b := s.broadcaster
+	b.mutex.Lock()
+	delete(b.subscribers, s)
+	b.mutex.Unlock()
+
+	s.close()
+}
+
+// Dropped returns the number of readouts which were dropped because the buffer of the subscriber was full.
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+func (s *Subscription) end() {
+	s.doneOnce.Do(func() { close(s.done) })
+}
+
+// close ends the subscription and closes C. Ending the subscription first releases a send which is blocked on it.
+func (s *Subscription) close() {
+	s.end()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.c)
+	}
+}
+
+func (s *Subscription) send(r Readout) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return
+	}
+
+	if s.policy == BlockWhenFull {
+		select {
+		case s.c <- r:
+		case <-s.done:
+		}
+		return
+	}
+
+	select {
+	case s.c <- r:
+	default:
+		atomic.AddUint64(&s.dropped, 1)
+	}
+}
@@ -0,0 +1,114 @@
+package smartmeter
+
+import (
+	"testing"
+	"time"
+)
+
+// TestBroadcaster tests that every subscriber receives the readouts according to its policy.
+func TestBroadcaster(t *testing.T) {
+	b := NewBroadcaster()
+	dropping := b.Subscribe(1, DropWhenFull)
+	blocking := b.Subscribe(0, BlockWhenFull)
+
+	rChan := make(chan Readout)
+	go b.Run(rChan)
+
+	start := time.Date(2020, 7, 1, 0, 0, 0, 0, time.UTC)
+	go func() {
+		for i := 0; i < 3; i++ {
+			rChan <- Readout{Timestamp: start.Add(time.Duration(i) * time.Second)}
+		}
+		close(rChan)
+	}()
+
+	for i := 0; i < 3; i++ {
+		r, ok := <-blocking.C
+		if !ok {
+			t.Fatalf("Expected 3 readouts for the blocking subscriber, got %d", i)
+		}
+		if expected := start.Add(time.Duration(i) * time.Second); !r.Timestamp.Equal(expected) {
+			t.Errorf("Expected readout of %s, got %s", expected, r.Timestamp)
+		}
+	}
+	if _, ok := <-blocking.C; ok {
+		t.Error("Expected the blocking subscription to be closed")
+	}
+
+	received := 0
+	for range dropping.C {
+		received++
+	}
+	if received != 1 || dropping.Dropped() != 2 {
+		t.Errorf("Expected 1 received and 2 dropped readouts, got %d and %d", received, dropping.Dropped())
+	}
+}
+
+// TestUnsubscribeWhileBlocked tests that unsubscribing releases a broadcast which is blocked on the subscriber.
+func TestUnsubscribeWhileBlocked(t *testing.T) {
+	b := NewBroadcaster()
+	s := b.Subscribe(0, BlockWhenFull)
+	other := b.Subscribe(1, DropWhenFull)
+
+	broadcasted := make(chan struct{})
+	go func() {
+		b.Broadcast(Readout{})
+		close(broadcasted)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	s.Unsubscribe()
+
+	select {
+	case <-broadcasted:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the broadcast to be released by unsubscribing")
+	}
+
+	if _, ok := <-s.C; ok {
+		t.Error("Expected the subscription to be closed")
+	}
+	if _, ok := <-other.C; !ok {
+		t.Error("Expected the other subscriber to receive the readout")
+	}
+
+	b.Close()
+	s.Unsubscribe()
+	if _, ok := <-b.Subscribe(1, DropWhenFull).C; ok {
+		t.Error("Expected subscriptions to a closed broadcaster to be closed")
+	}
+}
+
+// TestCloseWhileBlocked tests that subscribing and closing don't wait for a broadcast which is blocked on a subscriber.
+func TestCloseWhileBlocked(t *testing.T) {
+	b := NewBroadcaster()
+	s := b.Subscribe(0, BlockWhenFull)
+
+	broadcasted := make(chan struct{})
+	go func() {
+		b.Broadcast(Readout{})
+		close(broadcasted)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	closed := make(chan struct{})
+	go func() {
+		b.Subscribe(1, DropWhenFull).Unsubscribe()
+		b.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Expected subscribing and closing not to wait for the blocked broadcast")
+	}
+	select {
+	case <-broadcasted:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the broadcast to be released by closing")
+	}
+	if _, ok := <-s.C; ok {
+		t.Error("Expected the subscription to be closed")
+	}
+}
@@ -0,0 +1,67 @@
+package smartmeter
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// AllowMissingChecksum adds the option to accept telegrams without a checksum, as sent by DSMR 2.2 and 3 meters.
+var AllowMissingChecksum = false
+
+// ErrMissingChecksum is returned when a telegram does not contain a checksum.
+var ErrMissingChecksum = errors.New("telegram does not contain a checksum")
+
+var corruptTelegrams uint64
+
+// CorruptTelegrams returns the number of telegrams that have been dropped because of an invalid checksum.
+func CorruptTelegrams() uint64 {
+	return atomic.LoadUint64(&corruptTelegrams)
+}
+
+// VerifyChecksum verifies the CRC16 checksum following the exclamation mark of a telegram.
+// The checksum is calculated over all characters from the leading slash up to and including the exclamation mark.
+func VerifyChecksum(telegram string) error {
+	end := strings.LastIndex(telegram, "!")
+	if end < 0 {
+		return errors.New("telegram does not contain an end marker")
+	}
+
+	raw := strings.TrimSpace(telegram[end+1:])
+	if raw == "" {
+		return ErrMissingChecksum
+	}
+
+	expected, err := strconv.ParseUint(raw, 16, 16)
+	if err != nil {
+		return fmt.Errorf("invalid checksum %q: %w", raw, err)
+	}
+
+	start := strings.Index(telegram, "/")
+	if start < 0 || start > end {
+		return errors.New("telegram does not contain a start marker")
+	}
+
+	if actual := crc16([]byte(telegram[start : end+1])); actual != uint16(expected) {
+		return fmt.Errorf("checksum mismatch: expected %04X, calculated %04X", expected, actual)
+	}
+	return nil
+}
+
+// crc16 calculates the CRC16 (polynomial 0xA001, initial value 0) used by DSMR telegrams.
+func crc16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
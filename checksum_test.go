@@ -0,0 +1,35 @@
+package smartmeter
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestCRC16 tests the checksum calculation against the standard check value.
+func TestCRC16(t *testing.T) {
+	if actual := crc16([]byte("123456789")); actual != 0xBB3D {
+		t.Errorf("Expected BB3D, got %04X", actual)
+	}
+}
+
+// TestVerifyChecksum tests the verification of telegram checksums.
+func TestVerifyChecksum(t *testing.T) {
+	body := "/ISK5\\2M550T-1012\r\n\r\n1-0:1.7.0(00.123*kW)\r\n!"
+	checksum := crc16([]byte(body))
+
+	t.Run("Valid checksum", func(t *testing.T) {
+		if err := VerifyChecksum(body + fmt.Sprintf("%04X", checksum) + "\r\n"); err != nil {
+			t.Errorf("Expected no error, got %s", err)
+		}
+	})
+	t.Run("Invalid checksum", func(t *testing.T) {
+		if err := VerifyChecksum(body + fmt.Sprintf("%04X", checksum+1) + "\r\n"); err == nil {
+			t.Error("Expected an error, got nil")
+		}
+	})
+	t.Run("Missing checksum", func(t *testing.T) {
+		if err := VerifyChecksum(body + "\r\n"); err != ErrMissingChecksum {
+			t.Errorf("Expected ErrMissingChecksum, got %v", err)
+		}
+	})
+}
@@ -1,8 +1,10 @@
 package smartmeter
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -18,170 +20,165 @@ const Power DataRetrievalOption = 2
 // Totals indicates that the totals should be retrieved.
 const Totals DataRetrievalOption = 4
 
+// Phases indicates that the voltage, current and power per phase should be retrieved.
+const Phases DataRetrievalOption = 8
+
+// Water indicates that the water usage should be retrieved.
+const Water DataRetrievalOption = 16
+
+// Heat indicates that the district heating usage should be retrieved.
+const Heat DataRetrievalOption = 32
+
 // DataRetrievalOption is used to indicate which datapoints should be retrieved.
+// Options can be combined by adding them together, e.g. Gas + Power.
 type DataRetrievalOption int
 
+// dataRetrievalOptions contains every individual option in the order in which its datapoints are exported.
+var dataRetrievalOptions = []DataRetrievalOption{Gas, Power, Totals, Phases, Water, Heat}
+
 // NewDataRetrievalOption creates a new dataretrieval option from an integer.
 func NewDataRetrievalOption(i int) DataRetrievalOption {
-	if i >= int(Gas+Power+Totals) || i <= int(All) {
+	if i >= int(Gas+Power+Totals+Phases+Water+Heat) || i <= int(All) {
 		return All
 	}
 	return DataRetrievalOption(i)
 }
 
-func ReadoutsToCSV(readouts []ReadoutData, retrieve DataRetrievalOption) []byte {
-	header := getCSVHeader(retrieve)
-	mapCallback := getReadoutToCSVFunction(retrieve)
-	out := make([]string, len(readouts))
-	for i, v := range readouts {
-		out[i] = mapCallback(v)
-	}
-
-	return []byte(header + strings.Join(out, ""))
-}
-
-func getCSVHeader(retrieve DataRetrievalOption) string {
-	header := "Timestamp"
-
-	switch retrieve {
-	case Gas:
-		header += ",Gas received m3\n"
-		break
-
-	case Power:
-		header += ",Power delivered kWh,Power received kWh\n"
-		break
-
-	case Totals:
-		header += ",Total power delivered low tarif kWh,Total power delivered peak tarif kWh,Total power received low tarif kWh,Total power received peak tarif kWh\n"
-		break
-
-	case Gas + Power:
-		header += ",Gas received m3,Power delivered kWh,Power received kWh\n"
-		break
-
-	case Gas + Totals:
-		header += ",Gas received m3,Total power delivered low tarif kWh,Total power delivered peak tarif kWh,Total power received low tarif kWh,Total power received peak tarif kWh\n"
-		break
-
-	case Power + Totals:
-		header += ",Power delivered kWh,Power received kWh,Total power delivered low tarif kWh,Total power delivered peak tarif kWh,Total power received low tarif kWh,Total power received peak tarif kWh\n"
-		break
-
-	default:
-		header += ",Gas received m3,Power delivered kWh,Power received kWh,Total power delivered low tarif kWh,Total power delivered peak tarif kWh,Total power received low tarif kWh,Total power received peak tarif kWh\n"
-	}
-	return header
-}
-
-func getReadoutToCSVFunction(retrieve DataRetrievalOption) func(data ReadoutData) string {
-	switch retrieve {
-	case Gas:
-		return readoutToCSVLineGas
-	case Power:
-		return readoutToCSVLinePower
-	case Totals:
-		return readoutToCSVLineTotals
-	case Gas + Power:
-		return readoutToCSVLineGasPower
-	case Gas + Totals:
-		return readoutToCSVLineGasTotals
-	case Power + Totals:
-		return readoutToCSVLinePowerTotals
-	default:
-		return readoutToCSVAll
-	}
-}
-
-func readoutToCSVAll(v ReadoutData) string {
-	return fmt.Sprintf("%s,%.3f,%.3f,%.3f,%.3f,%.3f,%.3f,%.3f\n", v.Timestamp, v.GasReceived, v.PowerDelivered, v.PowerReceived, v.TotalPowerDeliveredLowTarif, v.TotalPowerDeliveredPeakTarif, v.TotalPowerReceivedLowTarif, v.TotalPowerReceivedPeakTarif)
+// Includes reports whether the datapoints of the given option should be retrieved.
+func (o DataRetrievalOption) Includes(option DataRetrievalOption) bool {
+	return o == All || o&option == option
 }
 
-func readoutToCSVLinePowerTotals(v ReadoutData) string {
-	return fmt.Sprintf("%s,%.3f,%.3f,%.3f,%.3f,%.3f,%.3f\n", v.Timestamp, v.PowerDelivered, v.PowerReceived, v.TotalPowerDeliveredLowTarif, v.TotalPowerDeliveredPeakTarif, v.TotalPowerReceivedLowTarif, v.TotalPowerReceivedPeakTarif)
+// exportField describes how a single datapoint of a ReadoutData is exported.
+type exportField struct {
+	name   string
+	header string
+	field  func(*ReadoutData) *float64
 }
 
-func readoutToCSVLineGasTotals(v ReadoutData) string {
-	return fmt.Sprintf("%s,%.3f,%.3f,%.3f,%.3f,%.3f\n", v.Timestamp, v.GasReceived, v.TotalPowerDeliveredLowTarif, v.TotalPowerDeliveredPeakTarif, v.TotalPowerReceivedLowTarif, v.TotalPowerReceivedPeakTarif)
+var exportFields = map[DataRetrievalOption][]exportField{
+	Gas: {
+		{"GasReceived", "Gas received m3", func(r *ReadoutData) *float64 { return &r.GasReceived }},
+	},
+	Power: {
+		{"PowerDelivered", "Power delivered kWh", func(r *ReadoutData) *float64 { return &r.PowerDelivered }},
+		{"PowerReceived", "Power received kWh", func(r *ReadoutData) *float64 { return &r.PowerReceived }},
+	},
+	Totals: {
+		{"TotalPowerDeliveredLowTarif", "Total power delivered low tarif kWh", func(r *ReadoutData) *float64 { return &r.TotalPowerDeliveredLowTarif }},
+		{"TotalPowerDeliveredPeakTarif", "Total power delivered peak tarif kWh", func(r *ReadoutData) *float64 { return &r.TotalPowerDeliveredPeakTarif }},
+		{"TotalPowerReceivedLowTarif", "Total power received low tarif kWh", func(r *ReadoutData) *float64 { return &r.TotalPowerReceivedLowTarif }},
+		{"TotalPowerReceivedPeakTarif", "Total power received peak tarif kWh", func(r *ReadoutData) *float64 { return &r.TotalPowerReceivedPeakTarif }},
+	},
+	Phases: {
+		{"VoltageL1", "Voltage L1 V", func(r *ReadoutData) *float64 { return &r.VoltageL1 }},
+		{"VoltageL2", "Voltage L2 V", func(r *ReadoutData) *float64 { return &r.VoltageL2 }},
+		{"VoltageL3", "Voltage L3 V", func(r *ReadoutData) *float64 { return &r.VoltageL3 }},
+		{"CurrentL1", "Current L1 A", func(r *ReadoutData) *float64 { return &r.CurrentL1 }},
+		{"CurrentL2", "Current L2 A", func(r *ReadoutData) *float64 { return &r.CurrentL2 }},
+		{"CurrentL3", "Current L3 A", func(r *ReadoutData) *float64 { return &r.CurrentL3 }},
+		{"PowerDeliveredL1", "Power delivered L1 kW", func(r *ReadoutData) *float64 { return &r.PowerDeliveredL1 }},
+		{"PowerDeliveredL2", "Power delivered L2 kW", func(r *ReadoutData) *float64 { return &r.PowerDeliveredL2 }},
+		{"PowerDeliveredL3", "Power delivered L3 kW", func(r *ReadoutData) *float64 { return &r.PowerDeliveredL3 }},
+		{"PowerReceivedL1", "Power received L1 kW", func(r *ReadoutData) *float64 { return &r.PowerReceivedL1 }},
+		{"PowerReceivedL2", "Power received L2 kW", func(r *ReadoutData) *float64 { return &r.PowerReceivedL2 }},
+		{"PowerReceivedL3", "Power received L3 kW", func(r *ReadoutData) *float64 { return &r.PowerReceivedL3 }},
+	},
+	Water: {
+		{"WaterReceived", "Water received m3", func(r *ReadoutData) *float64 { return &r.WaterReceived }},
+	},
+	Heat: {
+		{"HeatReceived", "Heat received GJ", func(r *ReadoutData) *float64 { return &r.HeatReceived }},
+	},
 }
 
-func readoutToCSVLineGasPower(v ReadoutData) string {
-	return fmt.Sprintf("%s,%.3f,%.3f,%.3f\n", v.Timestamp, v.GasReceived, v.PowerDelivered, v.PowerReceived)
+// exportFieldsFromDataRetrievalOption returns the fields to export for the given option.
+func exportFieldsFromDataRetrievalOption(retrieve DataRetrievalOption) []exportField {
+	var fields []exportField
+	for _, option := range dataRetrievalOptions {
+		if retrieve.Includes(option) {
+			fields = append(fields, exportFields[option]...)
+		}
+	}
+	return fields
 }
 
-func readoutToCSVLineTotals(v ReadoutData) string {
-	return fmt.Sprintf("%s,%.3f,%.3f,%.3f,%.3f\n", v.Timestamp, v.TotalPowerDeliveredLowTarif, v.TotalPowerDeliveredPeakTarif, v.TotalPowerReceivedLowTarif, v.TotalPowerReceivedPeakTarif)
-}
+// ReadoutsToCSV converts a slice of ReadoutData to csv whilst taking the given DataRetrievalOption into account.
+func ReadoutsToCSV(readouts []ReadoutData, retrieve DataRetrievalOption) []byte {
+	fields := exportFieldsFromDataRetrievalOption(retrieve)
 
-func readoutToCSVLinePower(v ReadoutData) string {
-	return fmt.Sprintf("%s,%.3f,%.3f\n", v.Timestamp, v.PowerDelivered, v.PowerReceived)
-}
+	var out strings.Builder
+	out.WriteString("Timestamp")
+	for _, f := range fields {
+		out.WriteString("," + f.header)
+	}
+	out.WriteString("\n")
+
+	for _, v := range readouts {
+		out.WriteString(v.Timestamp)
+		for _, f := range fields {
+			if value := f.field(&v); !v.isMissing(value) {
+				fmt.Fprintf(&out, ",%.3f", *value)
+			} else {
+				out.WriteString(",")
+			}
+		}
+		out.WriteString("\n")
+	}
 
-func readoutToCSVLineGas(v ReadoutData) string {
-	return fmt.Sprintf("%s,%.3f\n", v.Timestamp, v.GasReceived)
+	return []byte(out.String())
 }
 
 // ReadoutsToJSON converts a slice of ReadoutData to json whilst taking the given DataRetrievalOption into account.
 func ReadoutsToJSON(readouts []ReadoutData, retrieve DataRetrievalOption) []byte {
 	output := make([]interface{}, len(readouts))
+	fields := exportFieldsFromDataRetrievalOption(retrieve)
 
 	for k, v := range readouts {
-		o := make(map[string]interface{})
-		o["Timestamp"] = v.Timestamp
-
-		switch retrieve {
-		case Gas:
-			addGasToMap(o, v)
-			break
-
-		case Power:
-			addPowerToMap(o, v)
-			break
-
-		case Totals:
-			addTotalsToMap(o, v)
-			break
-
-		case Gas + Power:
-			addGasToMap(o, v)
-			addPowerToMap(o, v)
-			break
-
-		case Gas + Totals:
-			addGasToMap(o, v)
-			addTotalsToMap(o, v)
-			break
-
-		case Power + Totals:
-			addPowerToMap(o, v)
-			addTotalsToMap(o, v)
-			break
-		}
-
-		if len(o) > 1 {
-			output[k] = o
+		if retrieve == All {
+			output[k] = v
 			continue
 		}
 
-		output[k] = v
+		o := make(map[string]interface{})
+		o["Timestamp"] = v.Timestamp
+		for _, f := range fields {
+			if value := f.field(&v); !v.isMissing(value) {
+				o[f.name] = *value
+			} else {
+				o[f.name] = nil
+			}
+		}
+		output[k] = o
 	}
 
 	j, _ := json.Marshal(output)
 	return j
 }
 
-func addGasToMap(m map[string]interface{}, r ReadoutData) {
-	m["GasReceived"] = r.GasReceived
-}
-
-func addPowerToMap(m map[string]interface{}, r ReadoutData) {
-	m["PowerReceived"] = r.PowerReceived
-	m["PowerDelivered"] = r.PowerDelivered
-}
+// MarshalJSON encodes the readout data as an object with its timestamp, tarif and datapoints, in which missing
+// datapoints are null.
+func (r ReadoutData) MarshalJSON() ([]byte, error) {
+	var out bytes.Buffer
+	timestamp, _ := json.Marshal(r.Timestamp)
+	out.WriteString(`{"Timestamp":`)
+	out.Write(timestamp)
+
+	out.WriteString(`,"Tarif":`)
+	if r.missing&missingTarif != 0 {
+		out.WriteString("null")
+	} else {
+		out.WriteString(strconv.Itoa(r.Tarif))
+	}
 
-func addTotalsToMap(m map[string]interface{}, r ReadoutData) {
-	m["TotalPowerDeliveredLowTarif"] = r.TotalPowerDeliveredLowTarif
-	m["TotalPowerDeliveredPeakTarif"] = r.TotalPowerDeliveredPeakTarif
-	m["TotalPowerReceivedLowTarif"] = r.TotalPowerReceivedLowTarif
-	m["TotalPowerReceivedPeakTarif"] = r.TotalPowerReceivedPeakTarif
+	for _, f := range exportFieldsFromDataRetrievalOption(All) {
+		out.WriteString(`,"` + f.name + `":`)
+		if value := f.field(&r); !r.isMissing(value) {
+			out.WriteString(strconv.FormatFloat(*value, 'f', -1, 64))
+		} else {
+			out.WriteString("null")
+		}
+	}
+	out.WriteString("}")
+	return out.Bytes(), nil
 }
@@ -0,0 +1,80 @@
+package smartmeter
+
+import "testing"
+
+// exportTestReadouts returns a readout with every datapoint and one from which the gas and the tarif are missing.
+func exportTestReadouts() []ReadoutData {
+	complete := ReadoutData{
+		Timestamp:                    "2020-07-01 12:00:00",
+		Tarif:                        2,
+		PowerReceived:                1.1,
+		PowerDelivered:               2.2,
+		GasReceived:                  1234.567,
+		TotalPowerDeliveredLowTarif:  3333.333,
+		TotalPowerDeliveredPeakTarif: 4444.444,
+		TotalPowerReceivedLowTarif:   1111.111,
+		TotalPowerReceivedPeakTarif:  2222.222,
+		VoltageL1:                    231.1,
+		VoltageL2:                    232.2,
+		VoltageL3:                    233.3,
+		CurrentL1:                    4,
+		CurrentL2:                    5,
+		CurrentL3:                    6,
+		PowerReceivedL1:              0.211,
+		PowerReceivedL2:              0.411,
+		PowerReceivedL3:              0.611,
+		PowerDeliveredL1:             0.221,
+		PowerDeliveredL2:             0.421,
+		PowerDeliveredL3:             0.621,
+		WaterReceived:                111.222,
+		HeatReceived:                 12.345,
+	}
+
+	partial := complete
+	partial.Timestamp = "2020-07-01 12:01:00"
+	partial.GasReceived = 0
+	partial.missing = partial.missingBit(&partial.GasReceived) | missingTarif
+	return []ReadoutData{complete, partial}
+}
+
+// TestReadoutsToCSV tests the order of the exported columns and that missing datapoints are exported as empty cells.
+func TestReadoutsToCSV(t *testing.T) {
+	expected := "Timestamp,Gas received m3,Voltage L1 V,Voltage L2 V,Voltage L3 V,Current L1 A,Current L2 A," +
+		"Current L3 A,Power delivered L1 kW,Power delivered L2 kW,Power delivered L3 kW,Power received L1 kW," +
+		"Power received L2 kW,Power received L3 kW\n" +
+		"2020-07-01 12:00:00,1234.567,231.100,232.200,233.300,4.000,5.000,6.000,0.221,0.421,0.621,0.211,0.411,0.611\n" +
+		"2020-07-01 12:01:00,,231.100,232.200,233.300,4.000,5.000,6.000,0.221,0.421,0.621,0.211,0.411,0.611\n"
+	if actual := string(ReadoutsToCSV(exportTestReadouts(), Gas+Phases)); actual != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, actual)
+	}
+
+	expected = "Timestamp,Power delivered kWh,Power received kWh,Total power delivered low tarif kWh," +
+		"Total power delivered peak tarif kWh,Total power received low tarif kWh,Total power received peak tarif kWh," +
+		"Water received m3,Heat received GJ\n" +
+		"2020-07-01 12:00:00,2.200,1.100,3333.333,4444.444,1111.111,2222.222,111.222,12.345\n" +
+		"2020-07-01 12:01:00,2.200,1.100,3333.333,4444.444,1111.111,2222.222,111.222,12.345\n"
+	if actual := string(ReadoutsToCSV(exportTestReadouts(), Power+Totals+Water+Heat)); actual != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, actual)
+	}
+}
+
+// TestReadoutsToJSON tests the exported fields and that missing datapoints are exported as null.
+func TestReadoutsToJSON(t *testing.T) {
+	expected := `[{"GasReceived":1234.567,"PowerDelivered":2.2,"PowerReceived":1.1,"Timestamp":"2020-07-01 12:00:00"},` +
+		`{"GasReceived":null,"PowerDelivered":2.2,"PowerReceived":1.1,"Timestamp":"2020-07-01 12:01:00"}]`
+	if actual := string(ReadoutsToJSON(exportTestReadouts(), Gas+Power)); actual != expected {
+		t.Errorf("Expected %s, got %s", expected, actual)
+	}
+
+	values := `"PowerDelivered":2.2,"PowerReceived":1.1,"TotalPowerDeliveredLowTarif":3333.333,` +
+		`"TotalPowerDeliveredPeakTarif":4444.444,"TotalPowerReceivedLowTarif":1111.111,` +
+		`"TotalPowerReceivedPeakTarif":2222.222,"VoltageL1":231.1,"VoltageL2":232.2,"VoltageL3":233.3,` +
+		`"CurrentL1":4,"CurrentL2":5,"CurrentL3":6,"PowerDeliveredL1":0.221,"PowerDeliveredL2":0.421,` +
+		`"PowerDeliveredL3":0.621,"PowerReceivedL1":0.211,"PowerReceivedL2":0.411,"PowerReceivedL3":0.611,` +
+		`"WaterReceived":111.222,"HeatReceived":12.345}`
+	expected = `[{"Timestamp":"2020-07-01 12:00:00","Tarif":2,"GasReceived":1234.567,` + values + `,` +
+		`{"Timestamp":"2020-07-01 12:01:00","Tarif":null,"GasReceived":null,` + values + `]`
+	if actual := string(ReadoutsToJSON(exportTestReadouts(), All)); actual != expected {
+		t.Errorf("Expected %s, got %s", expected, actual)
+	}
+}
@@ -0,0 +1,138 @@
+package smartmeter
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DSMRVersion is a version of the DSMR specification a telegram can be encoded in.
+type DSMRVersion string
+
+const (
+	// DSMR22 encodes telegrams without timestamp and checksum, as sent by DSMR 2.2 and 3 meters.
+	DSMR22 DSMRVersion = "2.2"
+	// DSMR4 encodes telegrams as sent by DSMR 4.2 meters.
+	DSMR4 DSMRVersion = "4"
+	// DSMR5 encodes telegrams as sent by DSMR 5 meters.
+	DSMR5 DSMRVersion = "5"
+	// DSMR5B encodes telegrams as sent by Belgian eMUCS (DSMR 5 based) meters.
+	DSMR5B DSMRVersion = "5B"
+)
+
+// TelegramData contains the values which are encoded into a telegram.
+type TelegramData struct {
+	// Header is the identification line without the leading slash, e.g. ISK5\2M550T-1012.
+	Header      string
+	EquipmentID string
+	Timestamp   time.Time
+	Tarif       int
+	// EnergyImport contains the imported energy in kWh, indexed by tarif - 1.
+	EnergyImport [2]float64
+	// EnergyExport contains the exported energy in kWh, indexed by tarif - 1.
+	EnergyExport      [2]float64
+	PowerImport       float64
+	PowerExport       float64
+	PowerFailures     int64
+	LongPowerFailures int64
+	VoltageSags       [3]int64
+	VoltageSwells     [3]int64
+	Voltage           [3]float64
+	Current           [3]float64
+	PhasePowerImport  [3]float64
+	PhasePowerExport  [3]float64
+	Message           string
+	SubMeters         []SubMeterReading
+}
+
+// EncodeTelegram encodes the data into a telegram of the given DSMR version, including its checksum.
+func EncodeTelegram(data TelegramData, version DSMRVersion) string {
+	var lines []string
+	add := func(format string, args ...interface{}) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}
+
+	add("/%s", data.Header)
+	add("")
+
+	switch version {
+	case DSMR4:
+		add("1-3:0.2.8(42)")
+	case DSMR5:
+		add("1-3:0.2.8(50)")
+	case DSMR5B:
+		add("0-0:96.1.4(50217)")
+	}
+
+	if version != DSMR22 {
+		add("0-0:1.0.0(%s)", formatTimestamp(data.Timestamp))
+	}
+
+	add("0-0:96.1.1(%s)", hex.EncodeToString([]byte(data.EquipmentID)))
+	for tarif := LowTarif; tarif <= PeakTarif; tarif++ {
+		add("%s(%010.3f*kWh)", energyImportOBIS(tarif), data.EnergyImport[tarif-1])
+	}
+	for tarif := LowTarif; tarif <= PeakTarif; tarif++ {
+		add("%s(%010.3f*kWh)", energyExportOBIS(tarif), data.EnergyExport[tarif-1])
+	}
+	add("%s(%04d)", obisTarif, data.Tarif)
+	add("%s(%06.3f*kW)", obisPowerImport, data.PowerImport)
+	add("%s(%06.3f*kW)", obisPowerExport, data.PowerExport)
+
+	if version != DSMR22 {
+		add("0-0:96.7.21(%05d)", data.PowerFailures)
+		add("0-0:96.7.9(%05d)", data.LongPowerFailures)
+		add("1-0:99.97.0(0)(0-0:96.7.19)")
+		for phase := 1; phase <= 3; phase++ {
+			add("1-0:%d.32.0(%05d)", 12+20*phase, data.VoltageSags[phase-1])
+		}
+		for phase := 1; phase <= 3; phase++ {
+			add("1-0:%d.36.0(%05d)", 12+20*phase, data.VoltageSwells[phase-1])
+		}
+	}
+
+	add("0-0:96.13.0(%s)", hex.EncodeToString([]byte(data.Message)))
+
+	if version != DSMR22 {
+		for phase := 1; phase <= 3; phase++ {
+			add("%s(%05.1f*V)", voltageOBIS(phase), data.Voltage[phase-1])
+		}
+	}
+	for phase := 1; phase <= 3; phase++ {
+		add("%s(%03.0f*A)", currentOBIS(phase), data.Current[phase-1])
+	}
+	if version != DSMR22 {
+		for phase := 1; phase <= 3; phase++ {
+			add("%s(%06.3f*kW)", phasePowerImportOBIS(phase), data.PhasePowerImport[phase-1])
+		}
+		for phase := 1; phase <= 3; phase++ {
+			add("%s(%06.3f*kW)", phasePowerExportOBIS(phase), data.PhasePowerExport[phase-1])
+		}
+	}
+
+	for _, m := range data.SubMeters {
+		encodeSubMeter(add, m, version)
+	}
+
+	telegram := strings.Join(lines, "\r\n") + "\r\n!"
+	if version == DSMR22 {
+		return telegram + "\r\n"
+	}
+	return fmt.Sprintf("%s%04X\r\n", telegram, crc16([]byte(telegram)))
+}
+
+func encodeSubMeter(add func(format string, args ...interface{}), m SubMeterReading, version DSMRVersion) {
+	add("0-%d:24.1.0(%03d)", m.Channel, int(m.DeviceType))
+	add("0-%d:96.1.0(%s)", m.Channel, hex.EncodeToString([]byte(m.EquipmentID)))
+
+	switch version {
+	case DSMR22:
+		add("0-%d:24.3.0(%s)(00)(60)(1)(0-%d:24.2.1)(%s)", m.Channel, formatTimestamp(m.CapturedAt)[:12], m.Channel, m.Unit)
+		add("(%09.3f)", m.Value)
+	case DSMR5B:
+		add("0-%d:24.2.3(%s)(%09.3f*%s)", m.Channel, formatTimestamp(m.CapturedAt), m.Value, m.Unit)
+	default:
+		add("0-%d:24.2.1(%s)(%09.3f*%s)", m.Channel, formatTimestamp(m.CapturedAt), m.Value, m.Unit)
+	}
+}
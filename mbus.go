@@ -0,0 +1,247 @@
+package smartmeter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MBusChannels is the number of M-Bus channels of a meter.
+const MBusChannels = 4
+
+// MBusDeviceType is the type of a device connected to an M-Bus channel, as defined by EN 13757-3.
+type MBusDeviceType int
+
+const (
+	// GasDevice is a gas meter.
+	GasDevice MBusDeviceType = 3
+	// HeatDevice is a heat meter measuring at the outlet.
+	HeatDevice MBusDeviceType = 4
+	// WarmWaterDevice is a warm water meter.
+	WarmWaterDevice MBusDeviceType = 6
+	// WaterDevice is a water meter.
+	WaterDevice MBusDeviceType = 7
+	// CoolingDevice is a cooling meter measuring at the outlet.
+	CoolingDevice MBusDeviceType = 10
+	// CoolingInletDevice is a cooling meter measuring at the inlet.
+	CoolingInletDevice MBusDeviceType = 11
+	// HeatInletDevice is a heat meter measuring at the inlet.
+	HeatInletDevice MBusDeviceType = 12
+	// HeatCoolingDevice is a combined heat and cooling meter.
+	HeatCoolingDevice MBusDeviceType = 13
+)
+
+func (t MBusDeviceType) String() string {
+	switch t {
+	case GasDevice:
+		return "gas"
+	case HeatDevice, HeatInletDevice:
+		return "heat"
+	case WarmWaterDevice:
+		return "warm water"
+	case WaterDevice:
+		return "water"
+	case CoolingDevice, CoolingInletDevice:
+		return "cooling"
+	case HeatCoolingDevice:
+		return "heat/cooling"
+	}
+	return fmt.Sprintf("unknown (%d)", int(t))
+}
+
+// IsThermal reports whether the device measures thermal energy, such as heat or cooling.
+func (t MBusDeviceType) IsThermal() bool {
+	switch t {
+	case HeatDevice, CoolingDevice, CoolingInletDevice, HeatInletDevice, HeatCoolingDevice:
+		return true
+	}
+	return false
+}
+
+// SubMeterReading contains the last reading of a device connected to an M-Bus channel.
+type SubMeterReading struct {
+	Channel    int
+	DeviceType MBusDeviceType
+	Value      float64
+	Unit       Unit
+	// EquipmentID is the equipment identifier of the device.
+	EquipmentID string
+	// CapturedAt is the time at which the device was last read by the meter.
+	CapturedAt time.Time
+}
+
+// DeviceType returns the type of the device connected to the given M-Bus channel (1-4).
+func (r *Readout) DeviceType(channel int) (MBusDeviceType, bool) {
+	do, ok := r.telegram.DataObjects[fmt.Sprintf("0-%d:24.1.0", channel)]
+	if !ok {
+		return 0, false
+	}
+
+	t, err := strconv.Atoi(do.Value)
+	if err != nil {
+		return 0, false
+	}
+	return MBusDeviceType(t), true
+}
+
+// SubMeterReading returns the last reading of the device connected to the given M-Bus channel (1-4).
+// The reading has the form (capture timestamp)(value*unit). ErrMissingValue is returned when the telegram does not
+// contain a reading for the channel, and ErrInvalidValue when the reading can't be parsed.
+func (r *Readout) SubMeterReading(channel int) (SubMeterReading, error) {
+	obis := fmt.Sprintf("0-%d:24.2.1", channel)
+	values := r.rawValues(obis)
+	if values == nil {
+		// Belgian meters report the reading without temperature correction.
+		obis = fmt.Sprintf("0-%d:24.2.3", channel)
+		values = r.rawValues(obis)
+	}
+	if values == nil {
+		// DSMR 2.2 meters report (capture timestamp)(00)(60)(1)(0-n:24.2.1)(unit) followed by (value) on the next line.
+		obis = fmt.Sprintf("0-%d:24.3.0", channel)
+		if values = r.rawValues(obis); len(values) == 7 {
+			values = []string{values[0], values[6] + "*" + values[5]}
+		}
+	}
+	if values == nil {
+		return SubMeterReading{}, ErrMissingValue
+	}
+	if len(values) != 2 {
+		return SubMeterReading{}, fmt.Errorf("%w for %s: %q", ErrInvalidValue, obis, values)
+	}
+
+	value, unit := values[1], ""
+	if i := strings.Index(value, "*"); i >= 0 {
+		value, unit = value[:i], value[i+1:]
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return SubMeterReading{}, fmt.Errorf("%w for %s: %v", ErrInvalidValue, obis, err)
+	}
+
+	capturedAt, _ := parseTimestamp(values[0])
+	deviceType, _ := r.DeviceType(channel)
+	return SubMeterReading{
+		Channel:     channel,
+		DeviceType:  deviceType,
+		Value:       f,
+		Unit:        Unit(unit),
+		EquipmentID: r.SubMeterEquipmentID(channel),
+		CapturedAt:  capturedAt,
+	}, nil
+}
+
+// SubMeters returns the last readings of all devices connected to the M-Bus channels.
+func (r *Readout) SubMeters() []SubMeterReading {
+	var readings []SubMeterReading
+	for channel := 1; channel <= MBusChannels; channel++ {
+		if reading, err := r.SubMeterReading(channel); err == nil {
+			readings = append(readings, reading)
+		}
+	}
+	return readings
+}
+
+// SubMeter returns the last reading of the first device of one of the given types.
+func (r *Readout) SubMeter(deviceTypes ...MBusDeviceType) (SubMeterReading, bool) {
+	for _, reading := range r.SubMeters() {
+		for _, t := range deviceTypes {
+			if reading.DeviceType == t {
+				return reading, true
+			}
+		}
+	}
+	return SubMeterReading{}, false
+}
+
+// subMeterChannel returns the first M-Bus channel to which a device of one of the given types is connected, or 0 if
+// there is none.
+func (r *Readout) subMeterChannel(deviceTypes ...MBusDeviceType) int {
+	for channel := 1; channel <= MBusChannels; channel++ {
+		deviceType, ok := r.DeviceType(channel)
+		if !ok {
+			continue
+		}
+		for _, t := range deviceTypes {
+			if deviceType == t {
+				return channel
+			}
+		}
+	}
+	return 0
+}
+
+// Gas returns the m3 of gas received from the mains, as read from the channel of the gas meter.
+// ErrMissingValue is returned when there is no gas meter.
+func (r *Readout) Gas() (float64, error) {
+	channel := r.GasChannel()
+	if channel == 0 {
+		return 0, ErrMissingValue
+	}
+
+	reading, err := r.SubMeterReading(channel)
+	return reading.Value, err
+}
+
+// Water returns the m3 of water received from the mains in 1 dm3 resolution.
+// ErrMissingValue is returned when there is no water meter.
+func (r *Readout) Water() (float64, error) {
+	channel := r.subMeterChannel(WaterDevice)
+	if channel == 0 {
+		return 0, ErrMissingValue
+	}
+
+	reading, err := r.SubMeterReading(channel)
+	return reading.Value, err
+}
+
+// Heat returns the GJ of heat received from the district heating. Readings in kWh, MWh or GJ are converted to GJ.
+// ErrMissingValue is returned when there is no heat meter.
+func (r *Readout) Heat() (float64, error) {
+	channel := r.subMeterChannel(HeatDevice, HeatInletDevice, HeatCoolingDevice)
+	if channel == 0 {
+		return 0, ErrMissingValue
+	}
+
+	reading, err := r.SubMeterReading(channel)
+	if err != nil {
+		return 0, err
+	}
+
+	switch strings.ToLower(string(reading.Unit)) {
+	case "kwh":
+		return reading.Value * 0.0036, nil
+	case "mwh":
+		return reading.Value * 3.6, nil
+	}
+	return reading.Value, nil
+}
+
+// WaterReceived returns the m3 of water received from the mains in 1 dm3 resolution, or 0 if there is no water meter.
+func (r *Readout) WaterReceived() float64 {
+	f, _ := r.Water()
+	return f
+}
+
+// HeatReceived returns the GJ of heat received from the district heating, or 0 if there is no heat meter.
+func (r *Readout) HeatReceived() float64 {
+	f, _ := r.Heat()
+	return f
+}
+
+// GasChannel returns the M-Bus channel to which the gas meter is connected.
+// Telegrams without device types are assumed to have the gas meter on the first channel with a reading in m3.
+// 0 is returned when no gas meter could be found.
+func (r *Readout) GasChannel() int {
+	if channel := r.subMeterChannel(GasDevice); channel != 0 {
+		return channel
+	}
+
+	for _, reading := range r.SubMeters() {
+		if _, ok := r.DeviceType(reading.Channel); !ok && strings.EqualFold(string(reading.Unit), string(M3)) {
+			return reading.Channel
+		}
+	}
+	return 0
+}
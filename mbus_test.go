@@ -0,0 +1,96 @@
+package smartmeter
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// TestSubMeters tests finding the gas, water and heat meters on the M-Bus channels they are connected to.
+func TestSubMeters(t *testing.T) {
+	cases := []struct {
+		name       string
+		lines      string
+		gasChannel int
+		gas        float64
+		water      float64
+		heat       float64
+	}{
+		{
+			name: "Gas on channel 1",
+			lines: "0-1:24.1.0(003)\n" +
+				"0-1:24.2.1(200208140000W)(01234.567*m3)\n",
+			gasChannel: 1,
+			gas:        1234.567,
+		},
+		{
+			name: "Gas on channel 2",
+			lines: "0-1:24.1.0(007)\n" +
+				"0-1:24.2.1(200208140000W)(00111.222*m3)\n" +
+				"0-2:24.1.0(003)\n" +
+				"0-2:24.2.1(200208140000W)(01234.567*m3)\n",
+			gasChannel: 2,
+			gas:        1234.567,
+			water:      111.222,
+		},
+		{
+			name:       "Gas without device type",
+			lines:      "0-2:24.2.1(200208140000W)(01234.567*m3)\n",
+			gasChannel: 2,
+			gas:        1234.567,
+		},
+		{
+			name: "Belgian gas",
+			lines: "0-1:24.1.0(003)\n" +
+				"0-1:24.2.3(200208140000W)(01234.567*m3)\n",
+			gasChannel: 1,
+			gas:        1234.567,
+		},
+		{
+			name: "Heat in GJ",
+			lines: "0-3:24.1.0(004)\n" +
+				"0-3:24.2.1(200208140000W)(00012.345*GJ)\n",
+			heat: 12.345,
+		},
+		{
+			name: "Heat in kWh",
+			lines: "0-1:24.1.0(012)\n" +
+				"0-1:24.2.1(200208140000W)(01000.000*kWh)\n",
+			heat: 3.6,
+		},
+		{
+			name: "Heat in MWh",
+			lines: "0-4:24.1.0(013)\n" +
+				"0-4:24.2.1(200208140000W)(00002.500*MWh)\n",
+			heat: 9,
+		},
+		{
+			name: "Water and heat",
+			lines: "0-1:24.1.0(004)\n" +
+				"0-1:24.2.1(200208140000W)(00012.345*GJ)\n" +
+				"0-2:24.1.0(007)\n" +
+				"0-2:24.2.1(200208140000W)(00111.222*m3)\n",
+			water: 111.222,
+			heat:  12.345,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := testReadout(t, strings.Replace(testTelegram, "!", c.lines+"!", 1))
+
+			if actual := r.GasChannel(); actual != c.gasChannel {
+				t.Errorf("Expected gas on channel %d, got %d", c.gasChannel, actual)
+			}
+			if actual, err := r.Gas(); actual != c.gas || (c.gas == 0 && err != ErrMissingValue) {
+				t.Errorf("Expected gas %.3f, got %.3f (%v)", c.gas, actual, err)
+			}
+			if actual, err := r.Water(); actual != c.water || (c.water == 0 && err != ErrMissingValue) {
+				t.Errorf("Expected water %.3f, got %.3f (%v)", c.water, actual, err)
+			}
+			if actual, err := r.Heat(); math.Round(actual*1000)/1000 != c.heat || (c.heat == 0 && err != ErrMissingValue) {
+				t.Errorf("Expected heat %.3f, got %.3f (%v)", c.heat, actual, err)
+			}
+		})
+	}
+}
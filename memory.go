@@ -0,0 +1,317 @@
+package smartmeter
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+var _ Storage = (*Memory)(nil)
+
+// Memory provides an in-memory implementation of the storage backend, which keeps the readouts of a retention period
+// before the newest readout in a ring buffer. It is meant for live dashboards and as a reference for tests.
+// Readouts have to be inserted in chronological order.
+type Memory struct {
+	// Timestamps indicates which timestamp of a readout is stored.
+	Timestamps TimestampSource
+
+	mutex     sync.RWMutex
+	retention time.Duration
+	capacity  int
+	readouts  []ReadoutData
+	first     int
+	count     int
+	quality   []PowerQuality
+	failures  map[time.Time]time.Duration
+	messages  map[Message]*Message
+	closed    bool
+}
+
+// NewMemory creates an in-memory storage backend which keeps the readouts of the given retention, but never more than
+// the given capacity. A capacity of 0 or less allows one readout per second of the retention, the rate of DSMR 5 meters.
+// The buffer grows as readouts are inserted, until it reaches its capacity.
+func NewMemory(retention time.Duration, capacity int) *Memory {
+	if capacity <= 0 {
+		capacity = int(retention / time.Second)
+	}
+	if capacity <= 0 {
+		capacity = 1
+	}
+
+	return &Memory{
+		retention: retention,
+		capacity:  capacity,
+		failures:  make(map[time.Time]time.Duration),
+		messages:  make(map[Message]*Message),
+	}
+}
+
+// check returns the error to return when the storage can't be used.
+func (m *Memory) check(ctx context.Context) error {
+	if m.closed {
+		return ErrStorageClosed
+	}
+	return ctx.Err()
+}
+
+// at returns the i-th oldest readout in the ring buffer.
+func (m *Memory) at(i int) *ReadoutData {
+	return &m.readouts[(m.first+i)%len(m.readouts)]
+}
+
+// Insert inserts a readout, overwriting the oldest readout when the buffer is full.
+func (m *Memory) Insert(ctx context.Context, readout Readout) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if err := m.check(ctx); err != nil {
+		return err
+	}
+
+	timestamp := readout.Time(m.Timestamps).In(time.Local)
+	r := ReadoutDataFromReadout(readout)
+	r.timestamp = timestamp
+	r.Timestamp = timestamp.Format("2006-01-02 15:04:05")
+
+	if m.count == len(m.readouts) && len(m.readouts) < m.capacity {
+		m.grow()
+	} else if m.count == len(m.readouts) {
+		m.first = (m.first + 1) % len(m.readouts)
+		m.count--
+	}
+	*m.at(m.count) = r
+	m.count++
+
+	m.expire(timestamp)
+	return nil
+}
+
+// grow doubles the size of the full buffer, up to its capacity. The readouts are moved to the start of the new buffer.
+func (m *Memory) grow() {
+	size := 2 * len(m.readouts)
+	if size == 0 {
+		size = 1
+	}
+	if size > m.capacity {
+		size = m.capacity
+	}
+
+	readouts := make([]ReadoutData, size)
+	n := copy(readouts, m.readouts[m.first:])
+	copy(readouts[n:], m.readouts[:m.first])
+	m.readouts, m.first = readouts, 0
+}
+
+// expire removes everything that is older than the retention before the given time, except for the power quality
+// counters which were current at the start of the retention.
+func (m *Memory) expire(now time.Time) {
+	oldest := now.Add(-m.retention)
+
+	for m.count > 0 && m.at(0).timestamp.Before(oldest) {
+		*m.at(0) = ReadoutData{}
+		m.first = (m.first + 1) % len(m.readouts)
+		m.count--
+	}
+
+	i := 0
+	for i < len(m.quality)-1 && m.quality[i+1].Timestamp.Before(oldest) {
+		i++
+	}
+	m.quality = m.quality[i:]
+
+	for end := range m.failures {
+		if end.Before(oldest) {
+			delete(m.failures, end)
+		}
+	}
+
+	for key, message := range m.messages {
+		if message.LastSeen.Before(oldest) {
+			delete(m.messages, key)
+		}
+	}
+}
+
+// GetRange retrieves the selected datapoints of the readouts within the given range.
+// Like the SQL storage, every distinct gas reading is retrieved once, at the first readout containing it, when only the
+// gas is retrieved. Readouts without a gas reading are left out.
+func (m *Memory) GetRange(ctx context.Context, start time.Time, end time.Time, retrieve DataRetrievalOption) ([]ReadoutData, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	data := make([]ReadoutData, 0)
+	if err := m.check(ctx); err != nil {
+		return data, err
+	}
+
+	gasReadings := make(map[float64]bool)
+	for i := 0; i < m.count; i++ {
+		r := m.at(i)
+		if r.timestamp.Before(start) || r.timestamp.After(end) {
+			continue
+		}
+		if retrieve == Gas {
+			if r.isMissing(&r.GasReceived) || gasReadings[r.GasReceived] {
+				continue
+			}
+			gasReadings[r.GasReceived] = true
+		}
+		data = append(data, selectReadoutData(*r, retrieve))
+	}
+	return data, nil
+}
+
+// selectReadoutData returns a copy of the readout data which only contains the selected datapoints.
+func selectReadoutData(r ReadoutData, retrieve DataRetrievalOption) ReadoutData {
+	if retrieve == All {
+		return r
+	}
+
+	selected := ReadoutData{timestamp: r.timestamp, Timestamp: r.Timestamp}
+	for _, option := range dataRetrievalOptions {
+		if !retrieve.Includes(option) {
+			continue
+		}
+		dest := readoutColumnDestinations(option, &selected)
+		for i, f := range readoutColumnDestinations(option, &r) {
+			*dest[i] = *f
+			selected.missing |= r.missing & r.missingBit(f)
+		}
+	}
+	return selected
+}
+
+// GetAveragedRange retrieves the selected datapoints of the readouts within the given range and averages them over a
+// given interval.
+func (m *Memory) GetAveragedRange(ctx context.Context, start time.Time, end time.Time, interval time.Duration, retrieve DataRetrievalOption) ([]ReadoutData, error) {
+	completeRange, err := m.GetRange(ctx, start, end, retrieve)
+	if err != nil || interval == time.Second {
+		return completeRange, err
+	}
+	return averageReadouts(completeRange, interval), nil
+}
+
+// InsertPowerQuality inserts the power quality counters and power failures of a readout.
+// Like the SQL storage, counters are only stored when they change and power failures are only stored once.
+func (m *Memory) InsertPowerQuality(ctx context.Context, readout Readout) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if err := m.check(ctx); err != nil {
+		return err
+	}
+
+	quality := readout.PowerQuality()
+	quality.Timestamp = readout.Time(m.Timestamps).In(time.Local)
+	for _, f := range quality.FailureLog {
+		m.failures[f.End.In(time.Local)] = f.Duration
+	}
+	quality.FailureLog = nil
+
+	if len(m.quality) == 0 || !m.quality[len(m.quality)-1].countersEqual(quality) {
+		m.quality = append(m.quality, quality)
+	}
+	m.expire(quality.Timestamp)
+	return nil
+}
+
+// GetPowerQualityRange retrieves the power quality counters and power failures within the given range.
+func (m *Memory) GetPowerQualityRange(ctx context.Context, start time.Time, end time.Time) ([]PowerQuality, []PowerFailure, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	if err := m.check(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	counters := make([]PowerQuality, 0)
+	for _, q := range m.quality {
+		if !q.Timestamp.Before(start) && !q.Timestamp.After(end) {
+			counters = append(counters, q)
+		}
+	}
+
+	failures := make([]PowerFailure, 0)
+	for failureEnd, duration := range m.failures {
+		if !failureEnd.Before(start) && !failureEnd.After(end) {
+			failures = append(failures, PowerFailure{failureEnd, duration})
+		}
+	}
+	sort.Slice(failures, func(i, j int) bool { return failures[i].End.Before(failures[j].End) })
+
+	return counters, failures, nil
+}
+
+// GetPowerQualityReport retrieves a monthly power quality report for the given range.
+func (m *Memory) GetPowerQualityReport(ctx context.Context, start time.Time, end time.Time) ([]PowerQualityReport, error) {
+	counters, failures, err := m.GetPowerQualityRange(ctx, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	// The last counters before the range are the baseline of the events observed by the first counters in the range.
+	m.mutex.RLock()
+	var baseline *PowerQuality
+	for i, q := range m.quality {
+		if q.Timestamp.Before(start) && (baseline == nil || q.Timestamp.After(baseline.Timestamp)) {
+			baseline = &m.quality[i]
+		}
+	}
+	if baseline != nil {
+		counters = append([]PowerQuality{*baseline}, counters...)
+	}
+	m.mutex.RUnlock()
+
+	return PowerQualityPerMonth(counters, failures), nil
+}
+
+// InsertMessages inserts the consumer messages of a readout.
+// Messages are stored once, after which only the time they were last seen is updated.
+func (m *Memory) InsertMessages(ctx context.Context, readout Readout) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if err := m.check(ctx); err != nil {
+		return err
+	}
+
+	timestamp := readout.Time(m.Timestamps).In(time.Local)
+	for _, message := range readout.Messages() {
+		key := Message{Kind: message.Kind, Text: message.Text}
+		if known, ok := m.messages[key]; ok {
+			known.LastSeen = timestamp
+			continue
+		}
+
+		m.messages[key] = &Message{Kind: message.Kind, Text: message.Text, FirstSeen: timestamp, LastSeen: timestamp}
+	}
+	m.expire(timestamp)
+	return nil
+}
+
+// GetMessages retrieves the consumer messages that were seen within the given range.
+func (m *Memory) GetMessages(ctx context.Context, start time.Time, end time.Time) ([]Message, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	messages := make([]Message, 0)
+	if err := m.check(ctx); err != nil {
+		return messages, err
+	}
+
+	for _, message := range m.messages {
+		if !message.LastSeen.Before(start) && !message.FirstSeen.After(end) {
+			messages = append(messages, *message)
+		}
+	}
+	sort.Slice(messages, func(i, j int) bool { return messages[i].FirstSeen.Before(messages[j].FirstSeen) })
+	return messages, nil
+}
+
+// Close releases the stored data. The storage can't be used afterwards.
+func (m *Memory) Close() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.closed = true
+	m.readouts, m.quality, m.failures, m.messages = nil, nil, nil, nil
+	m.first, m.count = 0, 0
+	return nil
+}
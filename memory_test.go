@@ -0,0 +1,53 @@
+package smartmeter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestMemory tests the in-memory storage like every other storage backend.
+func TestMemory(t *testing.T) {
+	m := NewMemory(24*time.Hour, 0)
+	m.Timestamps = MeterTime
+	testStorage(t, m)
+}
+
+// TestMemoryBounds tests that the in-memory storage is bounded by its capacity and retention.
+func TestMemoryBounds(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2020, 7, 1, 12, 0, 0, 0, time.Local)
+	end := start.Add(time.Hour)
+
+	full := NewMemory(24*time.Hour, 10)
+	full.Timestamps = MeterTime
+	insertSimulated(t, full, start, 60)
+	if r, _ := full.GetRange(ctx, start, end, All); len(r) != 10 || r[0].Timestamp != "2020-07-01 12:50:00" {
+		t.Errorf("Expected the last 10 readouts from 12:50:00, got %d readouts", len(r))
+	}
+
+	expired := NewMemory(15*time.Minute, 0)
+	expired.Timestamps = MeterTime
+	insertSimulated(t, expired, start, 60)
+	if r, _ := expired.GetRange(ctx, start, end, All); len(r) != 16 || r[0].Timestamp != "2020-07-01 12:44:00" {
+		t.Errorf("Expected the readouts of the last 15 minutes from 12:44:00, got %d readouts", len(r))
+	}
+
+	if size := len(expired.readouts); size >= expired.capacity {
+		t.Errorf("Expected the buffer to grow with its readouts instead of holding %d, got %d", expired.capacity, size)
+	}
+
+	// The buffer grows after readouts expired, so its readouts wrap around the end.
+	growing := NewMemory(10*time.Minute, 0)
+	growing.Timestamps = MeterTime
+	insertSimulated(t, growing, start, 4)
+	insertSimulated(t, growing, start.Add(13*time.Minute), 9)
+	if r, _ := growing.GetRange(ctx, start, end, All); len(r) != 9 || r[0].Timestamp != "2020-07-01 12:13:00" || r[8].Timestamp != "2020-07-01 12:21:00" {
+		t.Errorf("Expected the readouts from 12:13:00 until 12:21:00, got %+v", r)
+	}
+
+	expired.Close()
+	if err := expired.Insert(ctx, Readout{}); err != ErrStorageClosed {
+		t.Errorf("Expected ErrStorageClosed, got %v", err)
+	}
+}
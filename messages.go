@@ -0,0 +1,132 @@
+package smartmeter
+
+import (
+	"encoding/hex"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// MessageKind indicates whether a message is a text or a code message.
+type MessageKind string
+
+const (
+	// TextMessage is a consumer text message (0-0:96.13.0).
+	TextMessage MessageKind = "text"
+	// CodeMessage is a consumer code message (0-0:96.13.1), which is only sent by DSMR 4 and older.
+	CodeMessage MessageKind = "code"
+)
+
+// Message is a consumer message sent by the grid operator.
+type Message struct {
+	Kind      MessageKind
+	Text      string
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+// TextMessage returns the consumer text message decoded to UTF-8, or an empty string if there is none.
+func (r *Readout) TextMessage() string {
+	return r.message("0-0:96.13.0")
+}
+
+// CodeMessage returns the consumer code message decoded to UTF-8, or an empty string if there is none.
+func (r *Readout) CodeMessage() string {
+	return r.message("0-0:96.13.1")
+}
+
+// Messages returns the consumer messages of the readout.
+func (r *Readout) Messages() []Message {
+	var messages []Message
+	if text := r.TextMessage(); text != "" {
+		messages = append(messages, Message{TextMessage, text, r.Timestamp, r.Timestamp})
+	}
+	if code := r.CodeMessage(); code != "" {
+		messages = append(messages, Message{CodeMessage, code, r.Timestamp, r.Timestamp})
+	}
+	return messages
+}
+
+func (r *Readout) message(obis string) string {
+	do, ok := r.telegram.DataObjects[obis]
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(decodeMessage(do.Value))
+}
+
+// decodeMessage decodes a hex encoded message. Messages that aren't valid UTF-8 are assumed to be ISO 8859-1.
+func decodeMessage(s string) string {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return s
+	}
+
+	if utf8.Valid(b) {
+		return string(b)
+	}
+
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+// MessageTracker deduplicates the consumer messages of consecutive readouts.
+type MessageTracker struct {
+	// OnMessage is called whenever a message is seen for the first time.
+	OnMessage func(Message)
+
+	mu       sync.Mutex
+	messages map[Message]*Message
+}
+
+// NewMessageTracker creates a new MessageTracker which calls the given function for every new message.
+func NewMessageTracker(onMessage func(Message)) *MessageTracker {
+	return &MessageTracker{OnMessage: onMessage}
+}
+
+// Track tracks the messages of the readout and returns the ones that haven't been seen before.
+func (t *MessageTracker) Track(r Readout) []Message {
+	t.mu.Lock()
+	if t.messages == nil {
+		t.messages = make(map[Message]*Message)
+	}
+
+	var added []Message
+	for _, m := range r.Messages() {
+		key := Message{Kind: m.Kind, Text: m.Text}
+		if known, ok := t.messages[key]; ok {
+			known.LastSeen = m.LastSeen
+			continue
+		}
+
+		m := m
+		t.messages[key] = &m
+		added = append(added, m)
+	}
+	t.mu.Unlock()
+
+	if t.OnMessage != nil {
+		for _, m := range added {
+			t.OnMessage(m)
+		}
+	}
+	return added
+}
+
+// Messages returns all messages that have been seen, ordered by the time they were first seen.
+func (t *MessageTracker) Messages() []Message {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	messages := make([]Message, 0, len(t.messages))
+	for _, m := range t.messages {
+		messages = append(messages, *m)
+	}
+	sort.Slice(messages, func(i, j int) bool { return messages[i].FirstSeen.Before(messages[j].FirstSeen) })
+	return messages
+}
@@ -0,0 +1,107 @@
+package smartmeter
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// MeterInfo identifies a physical meter.
+type MeterInfo struct {
+	EquipmentID string
+	// Header is the identification line of the telegram, which is only known for the electricity meter.
+	Header string
+	// Version is the DSMR version of the telegram, which is only known for the electricity meter.
+	Version string
+	// Channel is the M-Bus channel of a sub-meter, or 0 for the electricity meter.
+	Channel    int
+	DeviceType MBusDeviceType
+}
+
+// Header returns the identification line of the telegram without the leading slash, e.g. ISK5\2M550T-1012.
+func (r *Readout) Header() string {
+	for _, line := range strings.Split(r.raw, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "/") {
+			return line[1:]
+		}
+	}
+	return ""
+}
+
+// Version returns the DSMR version of the telegram, e.g. 50 for DSMR 5.0 or 50217 for Belgian eMUCS 1.7.1.
+// Telegrams of DSMR versions older than 4 do not contain a version.
+func (r *Readout) Version() string {
+	if do, ok := r.telegram.DataObjects["1-3:0.2.8"]; ok {
+		return do.Value
+	}
+	if do, ok := r.telegram.DataObjects["0-0:96.1.4"]; ok {
+		return do.Value
+	}
+	return ""
+}
+
+// EquipmentID returns the equipment identifier of the electricity meter.
+func (r *Readout) EquipmentID() string {
+	return r.equipmentID("0-0:96.1.1")
+}
+
+// SubMeterEquipmentID returns the equipment identifier of the device connected to the given M-Bus channel (1-4).
+func (r *Readout) SubMeterEquipmentID(channel int) string {
+	return r.equipmentID(fmt.Sprintf("0-%d:96.1.0", channel))
+}
+
+// equipmentID returns the equipment identifier with the given OBIS reference, which is decoded if it is hex encoded.
+func (r *Readout) equipmentID(obis string) string {
+	do, ok := r.telegram.DataObjects[obis]
+	if !ok {
+		return ""
+	}
+	return decodeHexString(do.Value)
+}
+
+// Meter returns the identity of the electricity meter.
+func (r *Readout) Meter() MeterInfo {
+	return MeterInfo{
+		EquipmentID: r.EquipmentID(),
+		Header:      r.Header(),
+		Version:     r.Version(),
+	}
+}
+
+// SubMeterInfos returns the identities of the devices connected to the M-Bus channels.
+func (r *Readout) SubMeterInfos() []MeterInfo {
+	var meters []MeterInfo
+	for channel := 1; channel <= MBusChannels; channel++ {
+		deviceType, ok := r.DeviceType(channel)
+		id := r.SubMeterEquipmentID(channel)
+		if !ok && id == "" {
+			continue
+		}
+
+		meters = append(meters, MeterInfo{
+			EquipmentID: id,
+			Channel:     channel,
+			DeviceType:  deviceType,
+		})
+	}
+	return meters
+}
+
+// decodeHexString decodes a hex encoded string, such as equipment identifiers and text messages.
+// The input is returned as is when it isn't hex encoded printable text.
+func decodeHexString(s string) string {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return s
+	}
+
+	decoded := string(b)
+	for _, c := range decoded {
+		if !unicode.IsPrint(c) && !unicode.IsSpace(c) {
+			return s
+		}
+	}
+	return decoded
+}
@@ -0,0 +1,298 @@
+package smartmeter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// migration changes the schema of the database from the previous version into its version.
+type migration struct {
+	version     int
+	description string
+	migrate     func(ctx context.Context, m *migrator) error
+}
+
+// migrations contains the migrations of the schema in the order in which they are applied.
+// Migrations are never changed once released, changes to the schema are made by appending a new migration.
+var migrations = []migration{
+	{1, "create the readouts table", func(ctx context.Context, m *migrator) error {
+		return m.createTable(ctx, "readouts", `
+			timestamp DATETIME,
+			date DATE,
+			time TIME,
+			tarif int,
+			power_received FLOAT,
+			power_deliverd FLOAT,
+			gas_received FLOAT,
+			total_power_received_low FLOAT,
+			total_power_received_peak FLOAT,
+			total_power_delivered_low FLOAT,
+			total_power_delivered_peak FLOAT`)
+	}},
+	{2, "add the voltage, current and power per phase", func(ctx context.Context, m *migrator) error {
+		return m.addColumns(ctx, "readouts",
+			"voltage_l1 FLOAT",
+			"voltage_l2 FLOAT",
+			"voltage_l3 FLOAT",
+			"current_l1 FLOAT",
+			"current_l2 FLOAT",
+			"current_l3 FLOAT",
+			"power_received_l1 FLOAT",
+			"power_received_l2 FLOAT",
+			"power_received_l3 FLOAT",
+			"power_delivered_l1 FLOAT",
+			"power_delivered_l2 FLOAT",
+			"power_delivered_l3 FLOAT",
+		)
+	}},
+	{3, "create the power quality tables", func(ctx context.Context, m *migrator) error {
+		err := m.createTable(ctx, "power_quality", `
+			timestamp DATETIME,
+			power_failures INT UNSIGNED,
+			long_power_failures INT UNSIGNED,
+			voltage_sags_l1 INT UNSIGNED,
+			voltage_sags_l2 INT UNSIGNED,
+			voltage_sags_l3 INT UNSIGNED,
+			voltage_swells_l1 INT UNSIGNED,
+			voltage_swells_l2 INT UNSIGNED,
+			voltage_swells_l3 INT UNSIGNED`)
+		if err != nil {
+			return err
+		}
+		return m.createTable(ctx, "power_failures", `
+			failure_end DATETIME UNIQUE,
+			duration INT UNSIGNED`)
+	}},
+	{4, "add the water and heat usage", func(ctx context.Context, m *migrator) error {
+		return m.addColumns(ctx, "readouts", "water_received FLOAT", "heat_received FLOAT")
+	}},
+	{5, "create the meters table", func(ctx context.Context, m *migrator) error {
+		err := m.createTable(ctx, "meters", `
+			equipment_id VARCHAR(96) UNIQUE,
+			channel TINYINT UNSIGNED,
+			device_type TINYINT UNSIGNED,
+			header VARCHAR(128),
+			version VARCHAR(8),
+			first_seen DATETIME`)
+		if err != nil {
+			return err
+		}
+		return m.addColumns(ctx, "readouts",
+			"meter_id INT UNSIGNED",
+			"gas_meter_id INT UNSIGNED",
+			"water_meter_id INT UNSIGNED",
+			"heat_meter_id INT UNSIGNED",
+		)
+	}},
+	{6, "create the messages table", func(ctx context.Context, m *migrator) error {
+		return m.createTable(ctx, "messages", `
+			hash CHAR(64) UNIQUE,
+			kind VARCHAR(8),
+			text TEXT,
+			first_seen DATETIME,
+			last_seen DATETIME`)
+	}},
+	{7, "rename power_deliverd to power_delivered and store readouts as decimals", func(ctx context.Context, m *migrator) error {
+		// FLOAT is a single precision float in MySQL, which can't hold meter totals like 123456.789 exactly.
+		// The meters report at most three decimals, which a DECIMAL(10,3) holds without loss.
+		changes := []columnChange{{"power_deliverd", "power_delivered", "DECIMAL(10,3)"}}
+		for _, column := range []string{
+			"power_received",
+			"gas_received",
+			"total_power_received_low",
+			"total_power_received_peak",
+			"total_power_delivered_low",
+			"total_power_delivered_peak",
+			"voltage_l1", "voltage_l2", "voltage_l3",
+			"current_l1", "current_l2", "current_l3",
+			"power_received_l1", "power_received_l2", "power_received_l3",
+			"power_delivered_l1", "power_delivered_l2", "power_delivered_l3",
+			"water_received",
+			"heat_received",
+		} {
+			changes = append(changes, columnChange{column, column, "DECIMAL(10,3)"})
+		}
+		return m.exec(ctx, m.dialect.alterColumns("readouts", changes)...)
+	}},
+}
+
+// columnChange renames a column and changes its type.
+type columnChange struct {
+	column string
+	name   string
+	typ    string
+}
+
+// sqlConn is implemented by both the connections and the transactions of a database.
+type sqlConn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// migrator applies a single migration within a transaction.
+type migrator struct {
+	tx      sqlConn
+	dialect *sqlDialect
+}
+
+// exec executes the statements, after converting their column types for the database.
+func (m *migrator) exec(ctx context.Context, statements ...string) error {
+	for _, q := range statements {
+		if _, err := m.tx.ExecContext(ctx, m.dialect.ddl(q)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createTable creates the table with an id and the given column definitions, unless it already exists.
+func (m *migrator) createTable(ctx context.Context, table string, columns string) error {
+	query := "CREATE TABLE IF NOT EXISTS " + table + " (\n\t\t\t" + m.dialect.idColumn(table) + "," + columns + "\n\t\t\t)"
+	return m.exec(ctx, append([]string{query}, m.dialect.tableSetup[table]...)...)
+}
+
+// addColumns adds the given column definitions to the table, skipping the columns which already exist.
+// Databases created before the schema was versioned may contain some of them already.
+func (m *migrator) addColumns(ctx context.Context, table string, definitions ...string) error {
+	existing, err := m.columns(ctx, table)
+	if err != nil {
+		return err
+	}
+
+	for _, definition := range definitions {
+		var column string
+		fmt.Sscan(definition, &column)
+		if existing[column] {
+			continue
+		}
+		if err := m.exec(ctx, "ALTER TABLE "+table+" ADD COLUMN "+definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// columns returns the names of the columns of the table.
+func (m *migrator) columns(ctx context.Context, table string) (map[string]bool, error) {
+	rows, err := m.tx.QueryContext(ctx, "SELECT * FROM "+table+" WHERE 1 = 0")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]bool)
+	for _, name := range names {
+		columns[name] = true
+	}
+	return columns, nil
+}
+
+// migrate brings the schema of the database up to date by applying the pending migrations in order.
+// Every migration is recorded in the schema_version table, along with the time it was applied. The schema is locked
+// while migrating, so processes which share the database wait for each other.
+func (s *SQL) migrate(ctx context.Context) (err error) {
+	d := s.sqlDialect()
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, d.lockSchema); err != nil {
+		return fmt.Errorf("locking the schema: %w", err)
+	}
+	defer func() {
+		unlock := d.unlockSchema
+		if err != nil && d.schemaTransaction {
+			unlock = "ROLLBACK"
+		}
+		// The lock is released even when the context is done.
+		if _, unlockErr := conn.ExecContext(context.Background(), unlock); unlockErr != nil && err == nil {
+			err = fmt.Errorf("unlocking the schema: %w", unlockErr)
+		}
+	}()
+
+	_, err = conn.ExecContext(ctx, d.ddl("CREATE TABLE IF NOT EXISTS schema_version (version INT UNSIGNED NOT NULL PRIMARY KEY, applied DATETIME)"))
+	if err != nil {
+		return err
+	}
+
+	var version int
+	if err := conn.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&version); err != nil {
+		return err
+	}
+
+	latest := migrations[len(migrations)-1].version
+	if version > latest {
+		return fmt.Errorf("database schema version %d is newer than the supported version %d", version, latest)
+	}
+
+	if version < latest {
+		// Views depend on the columns of the tables, so they are recreated after migrating.
+		for _, q := range d.dropViews {
+			if _, err := conn.ExecContext(ctx, q); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, mig := range migrations {
+		if mig.version <= version {
+			continue
+		}
+
+		log.Printf("Migrating the database to version %d: %s\n", mig.version, mig.description)
+		if err := s.applyMigration(ctx, conn, mig); err != nil {
+			return fmt.Errorf("migrating the database to version %d: %w", mig.version, err)
+		}
+	}
+
+	for _, q := range d.views {
+		if _, err := conn.ExecContext(ctx, q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyMigration applies the migration and records it within a single transaction.
+// MySQL commits schema changes implicitly, so there a failed migration can be applied partially.
+func (s *SQL) applyMigration(ctx context.Context, conn *sql.Conn, mig migration) error {
+	if s.sqlDialect().schemaTransaction {
+		// The schema lock is a transaction already, which is committed once all migrations are applied.
+		return s.recordMigration(ctx, conn, mig)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := s.recordMigration(ctx, tx, mig); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// recordMigration applies the migration and records it in the schema_version table.
+func (s *SQL) recordMigration(ctx context.Context, tx sqlConn, mig migration) error {
+	if err := mig.migrate(ctx, &migrator{tx: tx, dialect: s.sqlDialect()}); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, s.query(insertQuery("schema_version", "version", "applied")),
+		mig.version,
+		time.Now().Format("2006-01-02 15:04:05"),
+	)
+	return err
+}
@@ -0,0 +1,163 @@
+package smartmeter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// readoutAggregates contains the continuous aggregates of the readouts table, from the coarsest to the finest.
+var readoutAggregates = []struct {
+	view     string
+	interval time.Duration
+}{
+	{"readouts_daily", 24 * time.Hour},
+	{"readouts_hourly", time.Hour},
+}
+
+var postgresDialect = sqlDialect{
+	driver: "postgres",
+	idColumn: func(table string) string {
+		// The unique keys of a hypertable have to include its time column, so the id of a readout is no primary key.
+		if table == "readouts" {
+			return "id BIGSERIAL"
+		}
+		return "id BIGSERIAL PRIMARY KEY"
+	},
+	types: strings.NewReplacer("DATETIME", "TIMESTAMP", "TINYINT UNSIGNED", "SMALLINT", "INT UNSIGNED", "BIGINT"),
+	setup: []string{"CREATE EXTENSION IF NOT EXISTS timescaledb"},
+	tableSetup: map[string][]string{
+		"readouts": {"SELECT create_hypertable('readouts', 'timestamp', if_not_exists => TRUE, migrate_data => TRUE)"},
+	},
+	views:     readoutAggregateViews(),
+	dropViews: []string{"DROP MATERIALIZED VIEW IF EXISTS readouts_daily", "DROP MATERIALIZED VIEW IF EXISTS readouts_hourly"},
+	// Continuous aggregates can't be created within a transaction, so an advisory lock of the session is used.
+	lockSchema:   "SELECT pg_advisory_lock(hashtext('smartmeter_schema'))",
+	unlockSchema: "SELECT pg_advisory_unlock(hashtext('smartmeter_schema'))",
+	ignoreDuplicate: func(insert string) string {
+		return insert + " ON CONFLICT DO NOTHING"
+	},
+	updateDuplicate: updateOnConflict,
+	alterColumns: func(table string, changes []columnChange) []string {
+		var statements, clauses []string
+		for _, c := range changes {
+			if c.name != c.column {
+				statements = append(statements, "ALTER TABLE "+table+" RENAME COLUMN "+c.column+" TO "+c.name)
+			}
+			clauses = append(clauses, "ALTER COLUMN "+c.name+" TYPE "+c.typ+" USING "+c.name+"::"+c.typ)
+		}
+		// A single statement only rewrites the table once.
+		return append(statements, "ALTER TABLE "+table+" "+strings.Join(clauses, ", "))
+	},
+	placeholders: numberedPlaceholders,
+	timeBuckets:  true,
+}
+
+// NewPostgreSQL creates a storage backend which stores the readouts in a PostgreSQL database with the TimescaleDB
+// extension. The readouts table is a hypertable with hourly and daily continuous aggregates, which are used to average
+// readouts over long intervals. The application has to register the postgres driver, e.g. by importing github.com/lib/pq.
+func NewPostgreSQL(dsn string) *SQL {
+	return &SQL{Database: dsn, dialect: &postgresDialect}
+}
+
+// readoutAggregateViews returns the statements which create the continuous aggregates of the readouts table, which
+// contain the averages of all datapoints per bucket. Aggregates which are recreated after a migration are refreshed
+// immediately.
+func readoutAggregateViews() []string {
+	var statements []string
+
+	for _, a := range readoutAggregates {
+		bucket := postgresInterval(a.interval)
+		fields := []string{"time_bucket(" + bucket + ", timestamp) AS timestamp", "first(tarif, timestamp) AS tarif"}
+		for _, option := range dataRetrievalOptions {
+			for _, column := range readoutColumns[option] {
+				fields = append(fields, "AVG("+column+") AS "+column)
+			}
+		}
+
+		statements = append(statements,
+			"CREATE MATERIALIZED VIEW IF NOT EXISTS "+a.view+
+				" WITH (timescaledb.continuous, timescaledb.materialized_only = false) AS SELECT "+strings.Join(fields, ", ")+
+				" FROM readouts GROUP BY time_bucket("+bucket+", timestamp) WITH DATA",
+			fmt.Sprintf("SELECT add_continuous_aggregate_policy('%s', start_offset => %s, end_offset => %s, "+
+				"schedule_interval => INTERVAL '1 hour', if_not_exists => TRUE)",
+				a.view, postgresInterval(3*a.interval), bucket),
+		)
+	}
+	return statements
+}
+
+// getBucketedRange averages the readouts within the given range over the given interval in the database.
+// When the interval is a multiple of the buckets of a continuous aggregate, the averages in the aggregate are averaged.
+// Gas isn't retrieved on its own, as its distinct readings are averaged instead.
+func (s *SQL) getBucketedRange(ctx context.Context, start time.Time, end time.Time, interval time.Duration, retrieve DataRetrievalOption) ([]ReadoutData, error) {
+	data := make([]ReadoutData, 0)
+	if err := s.ensureInitialized(ctx); err != nil {
+		return data, err
+	}
+
+	table := "readouts"
+	for _, a := range readoutAggregates {
+		if interval%a.interval == 0 {
+			table = a.view
+			break
+		}
+	}
+
+	fields := []string{"time_bucket(?::interval, timestamp) AS bucket"}
+	if retrieve == All {
+		fields = append(fields, "first(tarif, timestamp)")
+	}
+	for _, option := range dataRetrievalOptions {
+		if !retrieve.Includes(option) {
+			continue
+		}
+		for _, column := range readoutColumns[option] {
+			fields = append(fields, "ROUND(AVG("+column+")::numeric, 3)")
+		}
+	}
+	q := "SELECT " + strings.Join(fields, ", ") + " FROM " + table +
+		" WHERE timestamp >= ? AND timestamp <= ? GROUP BY bucket ORDER BY bucket"
+
+	rows, err := s.db.QueryContext(ctx, s.query(q),
+		fmt.Sprintf("%d milliseconds", interval.Milliseconds()),
+		start.Format("2006-01-02 15:04:05"),
+		end.Format("2006-01-02 15:04:05"),
+	)
+	if err != nil {
+		log.Println(err)
+		return data, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r ReadoutData
+		if err := rows.Scan(scanDestinationsFromDataRetrievalOption(retrieve, &r)...); err != nil {
+			return data, err
+		}
+		data = append(data, r)
+	}
+	return data, rows.Err()
+}
+
+// postgresInterval formats a duration as an interval literal.
+func postgresInterval(d time.Duration) string {
+	return fmt.Sprintf("INTERVAL '%d seconds'", int64(d/time.Second))
+}
+
+// numberedPlaceholders converts the ? placeholders of a query into $1, $2, etc.
+func numberedPlaceholders(query string) string {
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c != '?' {
+			b.WriteRune(c)
+			continue
+		}
+		n++
+		fmt.Fprintf(&b, "$%d", n)
+	}
+	return b.String()
+}
@@ -0,0 +1,146 @@
+package smartmeter
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PowerFailure contains a single entry of the power failure event log.
+type PowerFailure struct {
+	End      time.Time
+	Duration time.Duration
+}
+
+// PowerQuality contains the power quality counters and the power failure event log of a readout.
+type PowerQuality struct {
+	Timestamp         time.Time
+	PowerFailures     int64
+	LongPowerFailures int64
+	VoltageSags       [3]int64
+	VoltageSwells     [3]int64
+	FailureLog        []PowerFailure
+}
+
+// countersEqual reports whether both power quality readings contain the same counters.
+func (p PowerQuality) countersEqual(o PowerQuality) bool {
+	return p.PowerFailures == o.PowerFailures &&
+		p.LongPowerFailures == o.LongPowerFailures &&
+		p.VoltageSags == o.VoltageSags &&
+		p.VoltageSwells == o.VoltageSwells
+}
+
+// PowerQualityReport summarizes the power quality events that occurred within a single month.
+type PowerQualityReport struct {
+	Month                 time.Time
+	PowerFailures         int64
+	LongPowerFailures     int64
+	LongPowerFailuresTime time.Duration
+	VoltageSags           [3]int64
+	VoltageSwells         [3]int64
+}
+
+// PowerFailures returns the number of power failures in any phase.
+func (r *Readout) PowerFailures() int64 {
+	return r.intValue("0-0:96.7.21")
+}
+
+// LongPowerFailures returns the number of long power failures in any phase.
+func (r *Readout) LongPowerFailures() int64 {
+	return r.intValue("0-0:96.7.9")
+}
+
+// VoltageSags returns the number of voltage sags in the given phase (1-3).
+func (r *Readout) VoltageSags(phase int) int64 {
+	return r.intValue(fmt.Sprintf("1-0:%d.32.0", 12+20*phase))
+}
+
+// VoltageSwells returns the number of voltage swells in the given phase (1-3).
+func (r *Readout) VoltageSwells(phase int) int64 {
+	return r.intValue(fmt.Sprintf("1-0:%d.36.0", 12+20*phase))
+}
+
+// PowerFailureLog returns the entries of the power failure event log.
+// The log has the form (count)(0-0:96.7.19)(end)(duration*s)(end)(duration*s)...
+func (r *Readout) PowerFailureLog() []PowerFailure {
+	values := r.rawValues("1-0:99.97.0")
+	if len(values) < 2 {
+		return nil
+	}
+
+	var failures []PowerFailure
+	entries := values[2:]
+	for i := 0; i+1 < len(entries); i += 2 {
+		end, err := parseTimestamp(entries[i])
+		if err != nil {
+			continue
+		}
+
+		seconds, err := strconv.ParseInt(strings.TrimSuffix(entries[i+1], "*s"), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		failures = append(failures, PowerFailure{end, time.Duration(seconds) * time.Second})
+	}
+	return failures
+}
+
+// PowerQuality returns the power quality counters and the power failure event log.
+func (r *Readout) PowerQuality() PowerQuality {
+	return PowerQuality{
+		Timestamp:         r.Timestamp,
+		PowerFailures:     r.PowerFailures(),
+		LongPowerFailures: r.LongPowerFailures(),
+		VoltageSags:       [3]int64{r.VoltageSags(1), r.VoltageSags(2), r.VoltageSags(3)},
+		VoltageSwells:     [3]int64{r.VoltageSwells(1), r.VoltageSwells(2), r.VoltageSwells(3)},
+		FailureLog:        r.PowerFailureLog(),
+	}
+}
+
+// PowerQualityPerMonth summarizes a chronologically ordered set of power quality counters and power failures per month.
+// Events are attributed to the month in which the counter increase was first observed, so the increase between the last
+// counters of a month and the first counters of the next month counts for the next month. The first counters are the
+// baseline of the second, so they should be the last counters before the summarized period.
+func PowerQualityPerMonth(counters []PowerQuality, failures []PowerFailure) []PowerQualityReport {
+	var reports []PowerQualityReport
+	index := make(map[time.Time]int)
+	report := func(t time.Time) *PowerQualityReport {
+		month := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+		i, ok := index[month]
+		if !ok {
+			i = len(reports)
+			index[month] = i
+			reports = append(reports, PowerQualityReport{Month: month})
+		}
+		return &reports[i]
+	}
+
+	for i := 1; i < len(counters); i++ {
+		prev, curr := counters[i-1], counters[i]
+		r := report(curr.Timestamp)
+		r.PowerFailures += positiveDelta(prev.PowerFailures, curr.PowerFailures)
+		r.LongPowerFailures += positiveDelta(prev.LongPowerFailures, curr.LongPowerFailures)
+		for phase := 0; phase < 3; phase++ {
+			r.VoltageSags[phase] += positiveDelta(prev.VoltageSags[phase], curr.VoltageSags[phase])
+			r.VoltageSwells[phase] += positiveDelta(prev.VoltageSwells[phase], curr.VoltageSwells[phase])
+		}
+	}
+
+	for _, f := range failures {
+		report(f.End).LongPowerFailuresTime += f.Duration
+	}
+
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Month.Before(reports[j].Month) })
+	return reports
+}
+
+// positiveDelta returns the increase of a counter, which is zero when the counter was reset.
+func positiveDelta(prev, curr int64) int64 {
+	if curr < prev {
+		return 0
+	}
+	return curr - prev
+}
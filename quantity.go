@@ -0,0 +1,49 @@
+package smartmeter
+
+import "strconv"
+
+// Unit is the unit of a quantity as reported by the meter.
+type Unit string
+
+// Units of the quantities in a telegram.
+const (
+	KW  Unit = "kW"
+	KWh Unit = "kWh"
+	M3  Unit = "m3"
+	V   Unit = "V"
+	A   Unit = "A"
+	GJ  Unit = "GJ"
+)
+
+// Quantity is a measured value along with its unit.
+type Quantity struct {
+	Value float64
+	Unit  Unit
+}
+
+func (q Quantity) String() string {
+	return strconv.FormatFloat(q.Value, 'f', -1, 64) + " " + string(q.Unit)
+}
+
+// quantity returns the data object with the given OBIS reference as a quantity.
+// The fallback unit is used when the telegram does not mention a unit.
+func (r *Readout) quantity(obis string, fallback Unit) (Quantity, error) {
+	f, err := r.Float(obis)
+	if err != nil {
+		return Quantity{}, err
+	}
+
+	unit := Unit(r.telegram.DataObjects[obis].Unit)
+	if unit == "" {
+		unit = fallback
+	}
+	return Quantity{f, unit}, nil
+}
+
+// valueOf returns the value of a quantity, or 0 if it is missing or invalid.
+func valueOf(q Quantity, err error) float64 {
+	if err != nil {
+		return 0
+	}
+	return q.Value
+}
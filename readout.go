@@ -1,58 +1,187 @@
 package smartmeter
 
 import (
+	"errors"
 	"fmt"
 	"github.com/roaldnefs/go-dsmr"
-	"math/rand"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
+	// The time zone database is embedded, so the timestamps of telegrams are converted correctly on systems without
+	// one, such as minimal container images.
+	_ "time/tzdata"
+)
+
+// ErrMissingValue is returned when the telegram does not contain the requested data object.
+var ErrMissingValue = errors.New("value missing from telegram")
+
+// ErrInvalidValue is returned when the value of the requested data object can't be parsed.
+var ErrInvalidValue = errors.New("invalid value")
+
+// OBIS references of the data objects read by the Readout accessors.
+// The DSMR specification names them from the perspective of the grid operator, so power "delivered" is imported by
+// the consumer and power "received" is exported by the consumer.
+const (
+	obisPowerImport = "1-0:1.7.0"
+	obisPowerExport = "1-0:2.7.0"
+	obisTarif       = "0-0:96.14.0"
+)
+
+func energyImportOBIS(tarif int) string {
+	return fmt.Sprintf("1-0:1.8.%d", tarif)
+}
+
+func energyExportOBIS(tarif int) string {
+	return fmt.Sprintf("1-0:2.8.%d", tarif)
+}
+
+func voltageOBIS(phase int) string {
+	return fmt.Sprintf("1-0:%d.7.0", 12+20*phase)
+}
+
+func currentOBIS(phase int) string {
+	return fmt.Sprintf("1-0:%d.7.0", 11+20*phase)
+}
+
+func phasePowerImportOBIS(phase int) string {
+	return fmt.Sprintf("1-0:%d.7.0", 1+20*phase)
+}
+
+func phasePowerExportOBIS(phase int) string {
+	return fmt.Sprintf("1-0:%d.7.0", 2+20*phase)
+}
+
+// TimestampSource indicates which timestamp of a readout should be used.
+type TimestampSource int
+
+const (
+	// ReceiveTime indicates the time at which the telegram was received.
+	ReceiveTime TimestampSource = iota
+	// MeterTime indicates the time at which the telegram was sent according to the meter.
+	MeterTime
 )
 
 // Readout contains relevant information from a dsmr telegram.
 type Readout struct {
+	// Timestamp is the time at which the telegram was received.
 	Timestamp time.Time
 	telegram  dsmr.Telegram
+	raw       string
 }
 
+// randomSimulator generates the telegrams of RandomReadout, so that its totals increase monotonically.
+var randomSimulator = NewSimulator(time.Now().UnixNano())
+var randomSimulatorMutex sync.Mutex
+
+// RandomReadout returns a readout of a simulated household at the current time.
 func RandomReadout() Readout {
-	t, err := dsmr.ParseTelegram(fmt.Sprintf(`/ISK5\2M550T-1012
-		0-0:1.0.0(190718204947S)
-		1-0:1.7.0(00.%d*kW)
-		1-0:2.7.0(00.%d*kW)
-		0-0:96.14.0(0001)
-		0-1:24.2.1(191118114002W)(00000.003*m3)
-		0-2:24.2.1(200208141004W)(00417.143*m3)
-		!0000`, rand.Intn(999), rand.Intn(999)))
+	now := time.Now()
+	randomSimulatorMutex.Lock()
+	raw := randomSimulator.Telegram(now)
+	randomSimulatorMutex.Unlock()
+
+	t, err := parseTelegram(raw)
 	if err != nil {
 		return Readout{
-			telegram: dsmr.Telegram{},
+			Timestamp: now,
+			telegram:  dsmr.Telegram{},
 		}
 	}
 
-	t.DateTime = time.Now()
+	t.DateTime = now
 	return Readout{
-		telegram: t,
+		Timestamp: now,
+		telegram:  t,
+		raw:       raw,
 	}
 }
 
-// PowerDelivered returns the kilowatts delivered to the grid in 1 watt resolution.
-func (r *Readout) PowerDelivered() float64 {
-	raw, ok := r.telegram.ActualElectricityPowerReceived()
+// MeterTimestamp returns the time at which the telegram was sent according to the meter.
+func (r *Readout) MeterTimestamp() (time.Time, bool) {
+	do, ok := r.telegram.DataObjects["0-0:1.0.0"]
 	if !ok {
-		return 0
+		return time.Time{}, false
 	}
-	f, _ := strconv.ParseFloat(raw, 64)
-	return f
+
+	t, err := parseTimestamp(do.Value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// Time returns the timestamp of the readout from the given source.
+// The receive time is returned when the telegram does not contain a valid meter timestamp.
+func (r *Readout) Time(source TimestampSource) time.Time {
+	if source == MeterTime {
+		if t, ok := r.MeterTimestamp(); ok {
+			return t
+		}
+	}
+	return r.Timestamp
+}
+
+// LowTarif is the tarif indicator of the low (night and weekend) tarif.
+const LowTarif = 1
+
+// PeakTarif is the tarif indicator of the peak (normal) tarif.
+const PeakTarif = 2
+
+// PowerImport returns the actual power imported from the grid (1-0:1.7.0) in kW with 1 W resolution.
+func (r *Readout) PowerImport() (Quantity, error) {
+	return r.quantity(obisPowerImport, KW)
+}
+
+// PowerExport returns the actual power exported to the grid (1-0:2.7.0) in kW with 1 W resolution.
+func (r *Readout) PowerExport() (Quantity, error) {
+	return r.quantity(obisPowerExport, KW)
+}
+
+// EnergyImport returns the total energy imported from the grid in the given tarif (1-0:1.8.1 and 1-0:1.8.2)
+// in kWh with 1 Wh resolution.
+func (r *Readout) EnergyImport(tarif int) (Quantity, error) {
+	return r.quantity(energyImportOBIS(tarif), KWh)
+}
+
+// EnergyExport returns the total energy exported to the grid in the given tarif (1-0:2.8.1 and 1-0:2.8.2)
+// in kWh with 1 Wh resolution.
+func (r *Readout) EnergyExport(tarif int) (Quantity, error) {
+	return r.quantity(energyExportOBIS(tarif), KWh)
+}
+
+// PhaseVoltage returns the instantaneous voltage of the given phase (1-3) in V with 0.1 V resolution.
+func (r *Readout) PhaseVoltage(phase int) (Quantity, error) {
+	return r.quantity(voltageOBIS(phase), V)
+}
+
+// PhaseCurrent returns the instantaneous current of the given phase (1-3) in A with 1 A resolution.
+func (r *Readout) PhaseCurrent(phase int) (Quantity, error) {
+	return r.quantity(currentOBIS(phase), A)
+}
+
+// PhasePowerImport returns the power imported from the grid on the given phase (1-3) in kW with 1 W resolution.
+func (r *Readout) PhasePowerImport(phase int) (Quantity, error) {
+	return r.quantity(phasePowerImportOBIS(phase), KW)
+}
+
+// PhasePowerExport returns the power exported to the grid on the given phase (1-3) in kW with 1 W resolution.
+func (r *Readout) PhasePowerExport(phase int) (Quantity, error) {
+	return r.quantity(phasePowerExportOBIS(phase), KW)
+}
+
+// PowerDelivered returns the kilowatts delivered to the grid in 1 watt resolution.
+//
+// Deprecated: use PowerExport.
+func (r *Readout) PowerDelivered() float64 {
+	return valueOf(r.PowerExport())
 }
 
 // PowerReceived returns the kilowatts recieved from the grid in 1 watt resolution.
+//
+// Deprecated: use PowerImport.
 func (r *Readout) PowerReceived() float64 {
-	raw, ok := r.telegram.ActualElectricityPowerDelivered()
-	if !ok {
-		return 0
-	}
-	f, _ := strconv.ParseFloat(raw, 64)
-	return f
+	return valueOf(r.PowerImport())
 }
 
 // GasReceived returns the m3 of gas received from the mains in 1mm3 resolution.
@@ -65,43 +194,143 @@ func (r *Readout) GasReceived(channel int) float64 {
 	return f
 }
 
-// TotalPowerReceivedLowTarif returns the total power received in the peak tarif in kWh with 1 Wh resolution.
+// TotalPowerReceivedLowTarif returns the total energy received from the grid in the low tarif in kWh with 1 Wh resolution.
+//
+// Deprecated: use EnergyImport(LowTarif).
 func (r *Readout) TotalPowerReceivedLowTarif() float64 {
-	raw, ok := r.telegram.MeterReadingElectricityDeliveredToClientTariff1()
-	if !ok {
-		return 0
-	}
-	f, _ := strconv.ParseFloat(raw, 64)
-	return f
+	return valueOf(r.EnergyImport(LowTarif))
 }
 
-// TotalPowerReceivedPeakTarif returns the total power received in the peak tarif in kWh with 1 Wh resolution.
+// TotalPowerReceivedPeakTarif returns the total energy received from the grid in the peak tarif in kWh with 1 Wh resolution.
+//
+// Deprecated: use EnergyImport(PeakTarif).
 func (r *Readout) TotalPowerReceivedPeakTarif() float64 {
-	raw, ok := r.telegram.MeterReadingElectricityDeliveredToClientTariff2()
-	if !ok {
-		return 0
-	}
-	f, _ := strconv.ParseFloat(raw, 64)
-	return f
+	return valueOf(r.EnergyImport(PeakTarif))
 }
 
-// TotalPowerDeliveredLowTarif returns the total power received in the peak tarif in kWh with 1 Wh resolution.
+// TotalPowerDeliveredLowTarif returns the total energy delivered to the grid in the low tarif in kWh with 1 Wh resolution.
+//
+// Deprecated: use EnergyExport(LowTarif).
 func (r *Readout) TotalPowerDeliveredLowTarif() float64 {
-	raw, ok := r.telegram.MeterReadingElectricityDeliveredByClientTariff1()
+	return valueOf(r.EnergyExport(LowTarif))
+}
+
+// TotalPowerDeliveredPeakTarif returns the total energy delivered to the grid in the peak tarif in kWh with 1 Wh resolution.
+//
+// Deprecated: use EnergyExport(PeakTarif).
+func (r *Readout) TotalPowerDeliveredPeakTarif() float64 {
+	return valueOf(r.EnergyExport(PeakTarif))
+}
+
+// Voltage returns the instantaneous voltage of the given phase (1-3) in V with 0.1 V resolution.
+//
+// Deprecated: use PhaseVoltage.
+func (r *Readout) Voltage(phase int) float64 {
+	return valueOf(r.PhaseVoltage(phase))
+}
+
+// Current returns the instantaneous current of the given phase (1-3) in A with 1 A resolution.
+//
+// Deprecated: use PhaseCurrent.
+func (r *Readout) Current(phase int) float64 {
+	return valueOf(r.PhaseCurrent(phase))
+}
+
+// PhasePowerReceived returns the kilowatts received from the grid on the given phase (1-3) in 1 watt resolution.
+//
+// Deprecated: use PhasePowerImport.
+func (r *Readout) PhasePowerReceived(phase int) float64 {
+	return valueOf(r.PhasePowerImport(phase))
+}
+
+// PhasePowerDelivered returns the kilowatts delivered to the grid on the given phase (1-3) in 1 watt resolution.
+//
+// Deprecated: use PhasePowerExport.
+func (r *Readout) PhasePowerDelivered(phase int) float64 {
+	return valueOf(r.PhasePowerExport(phase))
+}
+
+// rawValues returns the contents of every pair of parentheses on the raw telegram line with the given OBIS reference.
+// This is needed for data objects containing multiple values, which are not fully parsed by dsmr.ParseTelegram.
+func (r *Readout) rawValues(obis string) []string {
+	for _, line := range strings.Split(joinContinuationLines(r.raw), "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, obis+"(") {
+			continue
+		}
+
+		var values []string
+		for _, v := range strings.Split(line[len(obis)+1:], "(") {
+			values = append(values, strings.TrimSuffix(v, ")"))
+		}
+		return values
+	}
+	return nil
+}
+
+// parseTelegram parses a raw telegram, including the values which DSMR 2.2 meters send on a line of their own.
+func parseTelegram(raw string) (dsmr.Telegram, error) {
+	return dsmr.ParseTelegram(joinContinuationLines(raw))
+}
+
+// joinContinuationLines appends the lines which only contain a value, such as the reading of a sub meter of a DSMR
+// 2.2 meter on the line after 0-n:24.3.0, to the line of their data object.
+func joinContinuationLines(raw string) string {
+	lines := strings.Split(raw, "\n")
+	joined := lines[:0]
+	for _, line := range lines {
+		if strings.HasPrefix(line, "(") && len(joined) > 0 {
+			joined[len(joined)-1] = strings.TrimSuffix(joined[len(joined)-1], "\r") + line
+			continue
+		}
+		joined = append(joined, line)
+	}
+	return strings.Join(joined, "\n")
+}
+
+// Float returns the value of the data object with the given OBIS reference, e.g. 1-0:1.7.0, as a float.
+// ErrMissingValue is returned when the telegram does not contain the data object, and ErrInvalidValue when its value
+// is not a number.
+func (r *Readout) Float(obis string) (float64, error) {
+	do, ok := r.telegram.DataObjects[obis]
 	if !ok {
-		return 0
+		return 0, ErrMissingValue
 	}
-	f, _ := strconv.ParseFloat(raw, 64)
-	return f
+
+	f, err := strconv.ParseFloat(do.Value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w for %s: %v", ErrInvalidValue, obis, err)
+	}
+	return f, nil
 }
 
-// TotalPowerDeliveredPeakTarif returns the total power received in the peak tarif in kWh with 1 Wh resolution.
-func (r *Readout) TotalPowerDeliveredPeakTarif() float64 {
-	raw, ok := r.telegram.MeterReadingElectricityDeliveredByClientTariff2()
+// Int returns the value of the data object with the given OBIS reference, e.g. 0-0:96.14.0, as an integer.
+// ErrMissingValue is returned when the telegram does not contain the data object, and ErrInvalidValue when its value
+// is not an integer.
+func (r *Readout) Int(obis string) (int64, error) {
+	do, ok := r.telegram.DataObjects[obis]
 	if !ok {
-		return 0
+		return 0, ErrMissingValue
 	}
-	f, _ := strconv.ParseFloat(raw, 64)
+
+	i, err := strconv.ParseInt(do.Value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w for %s: %v", ErrInvalidValue, obis, err)
+	}
+	return i, nil
+}
+
+// intValue returns the value of the data object with the given OBIS reference as an integer, or 0 if it is missing
+// or invalid.
+func (r *Readout) intValue(obis string) int64 {
+	i, _ := r.Int(obis)
+	return i
+}
+
+// floatValue returns the value of the data object with the given OBIS reference as a float, or 0 if it is missing
+// or invalid.
+func (r *Readout) floatValue(obis string) float64 {
+	f, _ := r.Float(obis)
 	return f
 }
 
@@ -114,3 +343,51 @@ func (r *Readout) CurrentTarif() int64 {
 	f, _ := strconv.ParseInt(raw, 10, 64)
 	return f
 }
+
+// meterLocation is the time zone of the timestamps in telegrams.
+var meterLocation = loadMeterLocation()
+
+// loadMeterLocation loads the Europe/Amsterdam time zone.
+func loadMeterLocation() *time.Location {
+	loc, err := time.LoadLocation("Europe/Amsterdam")
+	if err != nil {
+		panic(err)
+	}
+	return loc
+}
+
+// parseTimestamp parses a DSMR timestamp (YYMMDDhhmmssX) in which X indicates summer (S) or winter (W) time.
+// The flag determines the UTC offset, which makes the repeated hour at the end of summer time unambiguous.
+// DSMR 2.2 meters send timestamps without the flag, which are parsed as local time.
+func parseTimestamp(raw string) (time.Time, error) {
+	if len(raw) == 12 {
+		return time.ParseInLocation("060102150405", raw, meterLocation)
+	}
+	if len(raw) != 13 {
+		return time.Time{}, fmt.Errorf("invalid timestamp: %q", raw)
+	}
+
+	offset := 1
+	switch raw[12] {
+	case 'S':
+		offset = 2
+	case 'W':
+	default:
+		return time.Time{}, fmt.Errorf("invalid timestamp: %q", raw)
+	}
+
+	t, err := time.ParseInLocation("060102150405", raw[:12], time.FixedZone("", offset*60*60))
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.In(meterLocation), nil
+}
+
+// formatTimestamp formats a time as a DSMR timestamp (YYMMDDhhmmssX).
+func formatTimestamp(t time.Time) string {
+	t = t.In(meterLocation)
+	if t.IsDST() {
+		return t.Format("060102150405") + "S"
+	}
+	return t.Format("060102150405") + "W"
+}
@@ -0,0 +1,158 @@
+package smartmeter
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testTelegram = `/ISK5\2M550T-1012
+1-3:0.2.8(50)
+0-0:1.0.0(200208141004W)
+1-0:1.8.1(001111.111*kWh)
+1-0:1.8.2(002222.222*kWh)
+1-0:2.8.1(003333.333*kWh)
+1-0:2.8.2(004444.444*kWh)
+0-0:96.14.0(0002)
+1-0:1.7.0(01.100*kW)
+1-0:2.7.0(02.200*kW)
+1-0:32.7.0(231.1*V)
+1-0:52.7.0(232.2*V)
+1-0:72.7.0(233.3*V)
+1-0:31.7.0(004*A)
+1-0:51.7.0(005*A)
+1-0:71.7.0(006*A)
+1-0:21.7.0(00.211*kW)
+1-0:41.7.0(00.411*kW)
+1-0:61.7.0(00.611*kW)
+1-0:22.7.0(00.221*kW)
+1-0:42.7.0(00.421*kW)
+1-0:62.7.0(00.621*kW)
+!`
+
+// testReadout parses a raw test telegram into a readout.
+func testReadout(t *testing.T, raw string) Readout {
+	telegram, err := parseTelegram(raw)
+	if err != nil {
+		t.Fatal("User error! Invalid test telegram:", err)
+	}
+	return Readout{telegram: telegram, raw: raw}
+}
+
+// TestOBISMapping pins the OBIS reference read by every accessor.
+func TestOBISMapping(t *testing.T) {
+	r := testReadout(t, testTelegram)
+
+	cases := []struct {
+		name     string
+		expected Quantity
+		actual   func() (Quantity, error)
+	}{
+		{"PowerImport", Quantity{1.1, KW}, r.PowerImport},
+		{"PowerExport", Quantity{2.2, KW}, r.PowerExport},
+		{"EnergyImport low", Quantity{1111.111, KWh}, func() (Quantity, error) { return r.EnergyImport(LowTarif) }},
+		{"EnergyImport peak", Quantity{2222.222, KWh}, func() (Quantity, error) { return r.EnergyImport(PeakTarif) }},
+		{"EnergyExport low", Quantity{3333.333, KWh}, func() (Quantity, error) { return r.EnergyExport(LowTarif) }},
+		{"EnergyExport peak", Quantity{4444.444, KWh}, func() (Quantity, error) { return r.EnergyExport(PeakTarif) }},
+		{"PhaseVoltage L2", Quantity{232.2, V}, func() (Quantity, error) { return r.PhaseVoltage(2) }},
+		{"PhaseCurrent L3", Quantity{6, A}, func() (Quantity, error) { return r.PhaseCurrent(3) }},
+		{"PhasePowerImport L1", Quantity{0.211, KW}, func() (Quantity, error) { return r.PhasePowerImport(1) }},
+		{"PhasePowerExport L3", Quantity{0.621, KW}, func() (Quantity, error) { return r.PhasePowerExport(3) }},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			actual, err := c.actual()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual != c.expected {
+				t.Errorf("Expected %s, got %s", c.expected, actual)
+			}
+		})
+	}
+}
+
+// TestDeprecatedAccessors tests that the deprecated accessors keep their meaning.
+func TestDeprecatedAccessors(t *testing.T) {
+	r := testReadout(t, testTelegram)
+
+	cases := []struct {
+		name     string
+		expected float64
+		actual   float64
+	}{
+		{"PowerReceived", 1.1, r.PowerReceived()},
+		{"PowerDelivered", 2.2, r.PowerDelivered()},
+		{"TotalPowerReceivedLowTarif", 1111.111, r.TotalPowerReceivedLowTarif()},
+		{"TotalPowerReceivedPeakTarif", 2222.222, r.TotalPowerReceivedPeakTarif()},
+		{"TotalPowerDeliveredLowTarif", 3333.333, r.TotalPowerDeliveredLowTarif()},
+		{"TotalPowerDeliveredPeakTarif", 4444.444, r.TotalPowerDeliveredPeakTarif()},
+	}
+
+	for _, c := range cases {
+		if c.actual != c.expected {
+			t.Errorf("%s: expected %.3f, got %.3f", c.name, c.expected, c.actual)
+		}
+	}
+}
+
+// TestMissingValue tests that missing values are distinguishable from zero.
+func TestMissingValue(t *testing.T) {
+	r := testReadout(t, testTelegram)
+
+	if _, err := r.Gas(); err != ErrMissingValue {
+		t.Errorf("Expected ErrMissingValue, got %v", err)
+	}
+}
+
+// TestInvalidValue tests that values which can't be parsed are reported as invalid and stored as missing.
+func TestInvalidValue(t *testing.T) {
+	raw := strings.Replace(testTelegram, "1-0:1.7.0(01.100*kW)", "1-0:1.7.0(01.1O0*kW)", 1)
+	raw = strings.Replace(raw, "0-0:96.14.0(0002)", "0-0:96.14.0(OO02)", 1)
+	raw = strings.Replace(raw, "!", "0-1:24.1.0(003)\n0-1:24.2.1(200208140000W)(0O123.456*m3)\n!", 1)
+	r := testReadout(t, raw)
+
+	if _, err := r.PowerImport(); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("Expected ErrInvalidValue for the power import, got %v", err)
+	}
+	if _, err := r.Int(obisTarif); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("Expected ErrInvalidValue for the tarif, got %v", err)
+	}
+	if _, err := r.Gas(); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("Expected ErrInvalidValue for the gas, got %v", err)
+	}
+
+	d := ReadoutDataFromReadout(r)
+	if !d.Missing("PowerReceived") || !d.Missing("Tarif") || !d.Missing("GasReceived") {
+		t.Errorf("Expected the invalid values to be missing, got %+v", d)
+	}
+	if d.Missing("PowerDelivered") || d.PowerDelivered != 2.2 {
+		t.Errorf("Expected a power export of 2.200, got %+v", d)
+	}
+}
+
+// TestTimestamps tests converting DSMR timestamps in summer and winter time.
+func TestTimestamps(t *testing.T) {
+	cases := []struct {
+		raw      string
+		expected time.Time
+	}{
+		{"200208141004W", time.Date(2020, 2, 8, 13, 10, 4, 0, time.UTC)},
+		{"200701120000S", time.Date(2020, 7, 1, 10, 0, 0, 0, time.UTC)},
+		// The hour before and after the end of summer time.
+		{"201025023000S", time.Date(2020, 10, 25, 0, 30, 0, 0, time.UTC)},
+		{"201025023000W", time.Date(2020, 10, 25, 1, 30, 0, 0, time.UTC)},
+	}
+
+	for _, c := range cases {
+		actual, err := parseTimestamp(c.raw)
+		if err != nil || !actual.Equal(c.expected) {
+			t.Errorf("Expected %s to be %s, got %s (%v)", c.raw, c.expected, actual, err)
+		}
+		if formatted := formatTimestamp(c.expected); formatted != c.raw {
+			t.Errorf("Expected %s to be formatted as %s, got %s", c.expected, c.raw, formatted)
+		}
+	}
+}
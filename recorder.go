@@ -0,0 +1,137 @@
+package smartmeter
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Recorder archives raw telegrams to gzip compressed files, starting a new file every day.
+// Pass it to ReadTelegrams using WithRecorder to record every valid telegram.
+type Recorder struct {
+	// Dir is the directory in which the files are stored.
+	Dir string
+	// MaxSize is the number of uncompressed bytes after which a new file is started. 0 means unlimited.
+	MaxSize int64
+	// MaxAge is the age after which a new file is started. 0 means unlimited.
+	MaxAge time.Duration
+	// Retention is the age after which files are removed. 0 keeps files forever.
+	Retention time.Duration
+
+	mu      sync.Mutex
+	file    *os.File
+	gz      *gzip.Writer
+	opened  time.Time
+	written int64
+	// now returns the current time, it is replaced by tests.
+	now func() time.Time
+}
+
+// NewRecorder creates a new Recorder which stores its files in the given directory.
+func NewRecorder(dir string) *Recorder {
+	return &Recorder{Dir: dir}
+}
+
+// Record appends the raw telegram to the current file.
+func (r *Recorder) Record(telegram string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if r.now != nil {
+		now = r.now()
+	}
+	if r.shouldRotate(now) {
+		if err := r.rotate(now); err != nil {
+			return err
+		}
+	}
+
+	n, err := r.gz.Write([]byte(telegram))
+	r.written += int64(n)
+	if err != nil {
+		return err
+	}
+
+	// Flush every telegram so that a crash loses as little as possible.
+	return r.gz.Flush()
+}
+
+// Close closes the current file.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.closeFile()
+}
+
+func (r *Recorder) shouldRotate(now time.Time) bool {
+	if r.file == nil {
+		return true
+	}
+
+	y, m, d := r.opened.Date()
+	ny, nm, nd := now.Date()
+	return y != ny || m != nm || d != nd ||
+		(r.MaxSize > 0 && r.written >= r.MaxSize) ||
+		(r.MaxAge > 0 && now.Sub(r.opened) >= r.MaxAge)
+}
+
+func (r *Recorder) rotate(now time.Time) error {
+	if err := r.closeFile(); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(r.Dir, 0755); err != nil {
+		return err
+	}
+
+	name := filepath.Join(r.Dir, "telegrams-"+now.Format("20060102-150405")+".txt.gz")
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	r.file = f
+	r.gz = gzip.NewWriter(f)
+	r.opened = now
+	r.written = 0
+
+	r.removeExpired(now)
+	return nil
+}
+
+func (r *Recorder) closeFile() error {
+	if r.file == nil {
+		return nil
+	}
+
+	err := r.gz.Close()
+	if cErr := r.file.Close(); err == nil {
+		err = cErr
+	}
+	r.file = nil
+	r.gz = nil
+	return err
+}
+
+// removeExpired removes the files which are older than the retention.
+func (r *Recorder) removeExpired(now time.Time) {
+	if r.Retention <= 0 {
+		return
+	}
+
+	files, _ := filepath.Glob(filepath.Join(r.Dir, "telegrams-*.txt.gz"))
+	for _, name := range files {
+		info, err := os.Stat(name)
+		if err != nil || r.file != nil && name == r.file.Name() {
+			continue
+		}
+
+		if now.Sub(info.ModTime()) > r.Retention {
+			os.Remove(name)
+		}
+	}
+}
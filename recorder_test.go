@@ -0,0 +1,122 @@
+package smartmeter
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readRecordings returns the uncompressed contents of the recorded files in the directory, oldest first.
+func readRecordings(t *testing.T, dir string) []string {
+	files, err := filepath.Glob(filepath.Join(dir, "telegrams-*.txt.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var contents []string
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(gz)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents = append(contents, string(b))
+	}
+	return contents
+}
+
+// TestRecorderRotation tests that the recorder starts a new file every day and whenever the current file is full.
+func TestRecorderRotation(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2020, 7, 1, 23, 59, 0, 0, time.Local)
+	r := NewRecorder(dir)
+	r.MaxSize = 20
+	r.now = func() time.Time { return now }
+
+	for _, telegram := range []string{"first\n", "second\n", "third one\n", "fourth\n"} {
+		if err := r.Record(telegram); err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(time.Second)
+	}
+	now = now.Add(time.Minute)
+	if err := r.Record("fifth\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	recordings := readRecordings(t, dir)
+	expected := []string{"first\nsecond\nthird one\n", "fourth\n", "fifth\n"}
+	if strings.Join(recordings, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected the files %q, got %q", expected, recordings)
+	}
+}
+
+// TestRecorderRetention tests that the recorder removes the files which are older than the retention.
+func TestRecorderRetention(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2020, 7, 1, 12, 0, 0, 0, time.Local)
+	old := filepath.Join(dir, "telegrams-20200701-090000.txt.gz")
+	recent := filepath.Join(dir, "telegrams-20200701-113000.txt.gz")
+	for name, age := range map[string]time.Duration{old: 3 * time.Hour, recent: 30 * time.Minute} {
+		if err := os.WriteFile(name, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(name, now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := NewRecorder(dir)
+	r.Retention = 2 * time.Hour
+	r.now = func() time.Time { return now }
+	if err := r.Record("telegram\n"); err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Errorf("Expected the file older than the retention to be removed, got %v", err)
+	}
+	if _, err := os.Stat(recent); err != nil {
+		t.Errorf("Expected the file within the retention to be kept, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "telegrams-20200701-120000.txt.gz")); err != nil {
+		t.Errorf("Expected the current file to be kept, got %v", err)
+	}
+}
+
+// TestReadTelegramsWithRecorder tests that the valid telegrams which are read are recorded.
+func TestReadTelegramsWithRecorder(t *testing.T) {
+	simulator := NewSimulator(1)
+	start := time.Date(2020, 7, 1, 12, 0, 0, 0, meterLocation)
+	first := simulator.Telegram(start)
+	second := simulator.Telegram(start.Add(time.Second))
+
+	dir := t.TempDir()
+	r := NewRecorder(dir)
+	rChan := make(chan Readout)
+	go ReadTelegramsContext(context.Background(), NewReaderSource(strings.NewReader(first+"/corrupt\r\n!0000\r\n"+second)), rChan, WithRecorder(r))
+	for range rChan {
+	}
+	r.Close()
+
+	if recordings := readRecordings(t, dir); len(recordings) != 1 || recordings[0] != first+second {
+		t.Errorf("Expected the valid telegrams to be recorded, got %q", recordings)
+	}
+}
@@ -0,0 +1,131 @@
+package smartmeter
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReplaySource replays a file containing raw P1 output as if it were received from a meter.
+// Gzip compressed files, such as the ones written by a Recorder, are decompressed when their name ends in .gz.
+type ReplaySource struct {
+	// Path is the path of the capture file.
+	Path string
+	// Speed determines how telegrams are paced using their timestamps.
+	// 1 replays in real time, 2 at twice the speed and so on. 0 replays as fast as possible.
+	Speed  float64
+	opened bool
+}
+
+// NewReplaySource creates a new ReplaySource which replays the given file at the given speed.
+func NewReplaySource(path string, speed float64) *ReplaySource {
+	return &ReplaySource{Path: path, Speed: speed}
+}
+
+// Open opens the capture file. A capture file can only be replayed once.
+func (r *ReplaySource) Open(ctx context.Context) (io.ReadCloser, error) {
+	if r.opened {
+		return nil, ErrSourceExhausted
+	}
+
+	f, err := openCaptureFile(r.Path)
+	if err != nil {
+		return nil, err
+	}
+	r.opened = true
+
+	if r.Speed <= 0 {
+		return &exhaustibleReader{f}, nil
+	}
+
+	pr, pw := io.Pipe()
+	p := &pacedReader{PipeReader: pr, done: make(chan struct{})}
+	go p.pace(f, pw, r.Speed)
+	return p, nil
+}
+
+// pacedReader provides the lines of a capture file, delaying every telegram by the time that passed between the
+// timestamps of consecutive telegrams.
+type pacedReader struct {
+	*io.PipeReader
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (p *pacedReader) pace(f io.ReadCloser, pw *io.PipeWriter, speed float64) {
+	defer f.Close()
+
+	var previous time.Time
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadString('\n')
+		if strings.HasPrefix(line, "0-0:1.0.0(") {
+			current, tErr := parseTimestamp(strings.TrimSuffix(strings.TrimSpace(line[10:]), ")"))
+			if tErr == nil && !previous.IsZero() && current.After(previous) {
+				select {
+				case <-time.After(time.Duration(float64(current.Sub(previous)) / speed)):
+				case <-p.done:
+					return
+				}
+			}
+			if tErr == nil {
+				previous = current
+			}
+		}
+
+		if _, wErr := pw.Write([]byte(line)); wErr != nil {
+			return
+		}
+
+		if err == io.EOF {
+			pw.CloseWithError(ErrSourceExhausted)
+			return
+		}
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+	}
+}
+
+// Close stops replaying the capture file.
+func (p *pacedReader) Close() error {
+	p.closeOnce.Do(func() { close(p.done) })
+	return p.PipeReader.Close()
+}
+
+// openCaptureFile opens a capture file, decompressing it if it is gzip compressed.
+func openCaptureFile(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasSuffix(path, ".gz") {
+		return f, nil
+	}
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &gzipFile{gz, f}, nil
+}
+
+// gzipFile decompresses a gzip compressed file.
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+// Close closes both the decompressor and the file.
+func (g *gzipFile) Close() error {
+	g.Reader.Close()
+	return g.file.Close()
+}
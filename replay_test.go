@@ -0,0 +1,82 @@
+package smartmeter
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestReplayPacing tests that telegrams are replayed with the time between their timestamps divided by the speed.
+func TestReplayPacing(t *testing.T) {
+	simulator := NewSimulator(1)
+	start := time.Date(2020, 7, 1, 12, 0, 0, 0, meterLocation)
+	var capture strings.Builder
+	for i := 0; i < 3; i++ {
+		capture.WriteString(simulator.Telegram(start.Add(time.Duration(i) * 10 * time.Second)))
+	}
+	path := filepath.Join(t.TempDir(), "capture.txt")
+	if err := os.WriteFile(path, []byte(capture.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rChan := make(chan Readout)
+	go ReadTelegramsContext(context.Background(), NewReplaySource(path, 50), rChan)
+	var received []time.Time
+	for range rChan {
+		received = append(received, time.Now())
+	}
+
+	if len(received) != 3 {
+		t.Fatalf("Expected 3 readouts, got %d", len(received))
+	}
+	for i := 1; i < len(received); i++ {
+		if gap := received[i].Sub(received[i-1]); gap < 150*time.Millisecond || gap > time.Second {
+			t.Errorf("Expected readout %d to follow 200ms after the previous one, got %s", i, gap)
+		}
+	}
+}
+
+// closeNotifier signals when it is closed.
+type closeNotifier struct {
+	io.Reader
+	closed chan struct{}
+}
+
+func (c *closeNotifier) Close() error {
+	close(c.closed)
+	return nil
+}
+
+// TestReplayClose tests that closing a paced replay stops the goroutine which is waiting for the next telegram.
+func TestReplayClose(t *testing.T) {
+	simulator := NewSimulator(1)
+	start := time.Date(2020, 7, 1, 12, 0, 0, 0, meterLocation)
+	first := simulator.Telegram(start)
+	second := simulator.Telegram(start.Add(time.Hour))
+	f := &closeNotifier{strings.NewReader(first + second), make(chan struct{})}
+
+	pr, pw := io.Pipe()
+	p := &pacedReader{PipeReader: pr, done: make(chan struct{})}
+	go p.pace(f, pw, 1)
+
+	// Reading up to the timestamp of the second telegram leaves the goroutine waiting for an hour.
+	expected := first + second[:strings.Index(second, "0-0:1.0.0(")]
+	b := make([]byte, len(expected))
+	if _, err := io.ReadFull(p, b); err != nil || string(b) != expected {
+		t.Fatalf("Expected the telegrams up to the second timestamp, got %q (%v)", b, err)
+	}
+	p.Close()
+
+	select {
+	case <-f.closed:
+	case <-time.After(time.Second):
+		t.Fatal("Expected closing to stop replaying the capture file")
+	}
+	if _, err := p.Read(b); err != io.ErrClosedPipe {
+		t.Errorf("Expected reading after closing to fail, got %v", err)
+	}
+}
@@ -0,0 +1,224 @@
+package smartmeter
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/tarm/serial"
+)
+
+// AutoDetectTimeout is the time spent waiting for a valid telegram per serial profile when auto detecting.
+var AutoDetectTimeout = time.Second * 30
+
+// serialReadTimeout is the longest time a single read from the serial port waits for data. Serial ports are blocking,
+// so closing them doesn't interrupt a read; reads time out instead to notice that reading has to stop.
+const serialReadTimeout = 500 * time.Millisecond
+
+// serialHangupReads is the number of reads in a row which return without data and without waiting for the read
+// timeout, after which the serial port is considered to be hung up, e.g. because the adapter was unplugged.
+const serialHangupReads = 3
+
+var (
+	errSerialSilent = errors.New("no data received from the serial port")
+	errSerialHangup = errors.New("serial port hung up")
+)
+
+// SerialConfig contains the parameters of the serial connection to the P1 port.
+type SerialConfig struct {
+	Port     string
+	Baud     int
+	DataBits byte
+	Parity   serial.Parity
+	StopBits serial.StopBits
+	// ReadTimeout is the longest time without data from the meter, after which reading fails and the port is
+	// reopened. It defaults to the AutoDetectTimeout.
+	ReadTimeout time.Duration
+	// AutoDetect indicates that the known serial profiles should be tried until valid telegrams are received.
+	// The Baud, DataBits, Parity and StopBits are ignored when auto detecting.
+	AutoDetect bool
+	// StateChanged is called whenever the state of the connection changes.
+	// The error contains the reason of the state change, if any.
+	StateChanged func(state ConnectionState, err error)
+}
+
+// DSMR4SerialConfig returns the serial configuration for DSMR 4 and 5 meters: 115200 baud 8N1.
+func DSMR4SerialConfig(port string) SerialConfig {
+	return SerialConfig{
+		Port:     port,
+		Baud:     115200,
+		DataBits: 8,
+		Parity:   serial.ParityNone,
+		StopBits: serial.Stop1,
+	}
+}
+
+// DSMR2SerialConfig returns the serial configuration for DSMR 2.2 and 3 meters: 9600 baud 7E1.
+func DSMR2SerialConfig(port string) SerialConfig {
+	return SerialConfig{
+		Port:     port,
+		Baud:     9600,
+		DataBits: 7,
+		Parity:   serial.ParityEven,
+		StopBits: serial.Stop1,
+	}
+}
+
+// AutoDetectSerialConfig returns a serial configuration which detects the serial profile of the meter.
+func AutoDetectSerialConfig(port string) SerialConfig {
+	return SerialConfig{Port: port, AutoDetect: true}
+}
+
+// serialProfiles contains the known serial configurations, in the order in which they are tried when auto detecting.
+var serialProfiles = []func(port string) SerialConfig{DSMR4SerialConfig, DSMR2SerialConfig}
+
+func (c SerialConfig) serialConfig() *serial.Config {
+	return &serial.Config{
+		Name:        c.Port,
+		Baud:        c.Baud,
+		Size:        c.DataBits,
+		Parity:      c.Parity,
+		StopBits:    c.StopBits,
+		ReadTimeout: serialReadTimeout,
+	}
+}
+
+// Open opens the serial port, after detecting the serial profile of the meter if needed.
+// The detected profile is remembered, so it isn't detected again when the port is reopened.
+func (c *SerialConfig) Open(ctx context.Context) (io.ReadCloser, error) {
+	detected, err := c.detect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	*c = detected
+
+	silence := c.ReadTimeout
+	if silence <= 0 {
+		silence = AutoDetectTimeout
+	}
+
+	port, err := serial.OpenPort(c.serialConfig())
+	if err != nil {
+		return nil, err
+	}
+	return newSerialPort(port, ctx, silence), nil
+}
+
+// serialPort retries the reads of a serial port which timed out until the context is done, data is received or the
+// port stays silent for too long.
+type serialPort struct {
+	*serial.Port
+	ctx      context.Context
+	silence  time.Duration
+	received time.Time
+}
+
+func newSerialPort(port *serial.Port, ctx context.Context, silence time.Duration) *serialPort {
+	return &serialPort{Port: port, ctx: ctx, silence: silence, received: time.Now()}
+}
+
+// Read reads from the serial port, which reports a read that timed out as io.EOF. A port which hung up reports
+// io.EOF as well, but right away instead of after the read timeout.
+func (p *serialPort) Read(b []byte) (int, error) {
+	hangups := 0
+	for {
+		start := time.Now()
+		n, err := p.Port.Read(b)
+		if n > 0 {
+			p.received = time.Now()
+		}
+		if n > 0 || err != io.EOF {
+			return n, err
+		}
+		if p.ctx.Err() != nil {
+			return 0, p.ctx.Err()
+		}
+
+		if time.Since(start) < serialReadTimeout/2 {
+			hangups++
+			if hangups >= serialHangupReads {
+				return 0, errSerialHangup
+			}
+		} else {
+			hangups = 0
+		}
+		if time.Since(p.received) >= p.silence {
+			return 0, errSerialSilent
+		}
+	}
+}
+
+func (c SerialConfig) stateChanged(state ConnectionState, err error) {
+	if c.StateChanged != nil {
+		c.StateChanged(state, err)
+	}
+}
+
+// detect tries the known serial profiles and returns the first one with which a valid telegram is received.
+func (c SerialConfig) detect(ctx context.Context) (SerialConfig, error) {
+	if !c.AutoDetect {
+		return c, nil
+	}
+
+	for _, profile := range serialProfiles {
+		candidate := c
+		p := profile(c.Port)
+		candidate.Baud, candidate.DataBits, candidate.Parity, candidate.StopBits = p.Baud, p.DataBits, p.Parity, p.StopBits
+		candidate.AutoDetect = false
+
+		log.Printf("Trying serial profile %d baud %d%c%d\n", p.Baud, p.DataBits, p.Parity, p.StopBits)
+		ok, err := candidate.probe(ctx)
+		if err != nil {
+			return c, err
+		}
+		if ok {
+			return candidate, nil
+		}
+	}
+
+	return c, errors.New("no valid telegrams received with any of the known serial profiles")
+}
+
+// probe reports whether a valid telegram is received within the AutoDetectTimeout.
+func (c SerialConfig) probe(ctx context.Context) (bool, error) {
+	s, err := serial.OpenPort(c.serialConfig())
+	if err != nil {
+		return false, err
+	}
+	defer s.Close()
+
+	deadline := time.Now().Add(AutoDetectTimeout)
+	reader := bufio.NewReader(newSerialPort(s, ctx, AutoDetectTimeout))
+	telegram := ""
+	for time.Now().Before(deadline) {
+		line, err := reader.ReadString('\n')
+		if err == errSerialSilent {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		if strings.HasPrefix(line, "/") {
+			telegram = ""
+		}
+		telegram += line
+
+		if !strings.HasPrefix(line, "!") || !strings.HasPrefix(telegram, "/") {
+			continue
+		}
+
+		err = VerifyChecksum(telegram)
+		if err == ErrMissingChecksum {
+			_, err = parseTelegram(telegram)
+		}
+		if err == nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,141 @@
+package smartmeter
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Simulator generates telegrams of a plausible household with solar panels and a gas meter.
+// Simulators created with the same seed generate the same telegrams for the same times.
+type Simulator struct {
+	// Version is the DSMR version of the generated telegrams.
+	Version DSMRVersion
+	// BaseLoad is the power in kW that is always consumed.
+	BaseLoad float64
+	// SolarPeak is the power in kW generated by the solar panels at noon on a clear day, 0 without solar panels.
+	SolarPeak float64
+	// GasPerHour is the average gas usage in m3 per hour.
+	GasPerHour float64
+
+	rand    *rand.Rand
+	data    TelegramData
+	gas     float64
+	started bool
+}
+
+// NewSimulator creates a new DSMR 5 simulator with the given seed.
+func NewSimulator(seed int64) *Simulator {
+	r := rand.New(rand.NewSource(seed))
+	return &Simulator{
+		Version:    DSMR5,
+		BaseLoad:   0.15,
+		SolarPeak:  3.5,
+		GasPerHour: 0.1,
+		rand:       r,
+		data: TelegramData{
+			Header:       `ISK5\2M550T-1012`,
+			EquipmentID:  "E0043007000000000",
+			EnergyImport: [2]float64{round(1000+r.Float64()*5000, 3), round(1000+r.Float64()*5000, 3)},
+			EnergyExport: [2]float64{round(r.Float64()*1000, 3), round(r.Float64()*2000, 3)},
+			SubMeters: []SubMeterReading{{
+				Channel:     1,
+				DeviceType:  GasDevice,
+				Unit:        M3,
+				EquipmentID: "G0043007000000000",
+				Value:       round(r.Float64()*3000, 3),
+			}},
+		},
+	}
+}
+
+// Telegram advances the simulation to the given time and returns the encoded telegram.
+func (s *Simulator) Telegram(t time.Time) string {
+	return EncodeTelegram(s.Next(t), s.Version)
+}
+
+// Next advances the simulation to the given time and returns the data of its telegram.
+// Times before the previous time do not advance the totals.
+func (s *Simulator) Next(t time.Time) TelegramData {
+	elapsed := t.Sub(s.data.Timestamp).Hours()
+	if !s.started || elapsed < 0 {
+		elapsed = 0
+	}
+	s.started = true
+
+	local := t.In(meterLocation)
+	hour := float64(local.Hour()) + float64(local.Minute())/60
+	s.data.Timestamp = t
+	s.data.Tarif = simulatedTarif(local)
+
+	// The load consists of the base load, an evening peak and random appliances switching on and off.
+	load := s.BaseLoad + s.rand.Float64()*0.1
+	if hour >= 17 && hour < 22 {
+		load += 0.5 + s.rand.Float64()*0.5
+	}
+	if s.rand.Float64() < 0.05 {
+		load += 1 + s.rand.Float64()*2
+	}
+
+	// Solar panels generate between 07:00 and 19:00, peaking at noon and dimmed by passing clouds.
+	solar := 0.0
+	if hour > 7 && hour < 19 {
+		solar = s.SolarPeak * math.Sin(math.Pi*(hour-7)/12) * (0.5 + s.rand.Float64()*0.5)
+	}
+
+	net := load - solar
+	s.data.PowerImport = round(math.Max(net, 0), 3)
+	s.data.PowerExport = round(math.Max(-net, 0), 3)
+	s.data.EnergyImport[s.data.Tarif-1] = round(s.data.EnergyImport[s.data.Tarif-1]+s.data.PowerImport*elapsed, 3)
+	s.data.EnergyExport[s.data.Tarif-1] = round(s.data.EnergyExport[s.data.Tarif-1]+s.data.PowerExport*elapsed, 3)
+
+	for phase := 0; phase < 3; phase++ {
+		s.data.Voltage[phase] = round(230+s.rand.NormFloat64()*1.5, 1)
+		s.data.PhasePowerImport[phase] = 0
+		s.data.PhasePowerExport[phase] = 0
+	}
+	// Solar panels and most appliances are connected to the first phase.
+	s.data.PhasePowerImport[0] = s.data.PowerImport
+	s.data.PhasePowerExport[0] = s.data.PowerExport
+	for phase := 0; phase < 3; phase++ {
+		power := s.data.PhasePowerImport[phase] + s.data.PhasePowerExport[phase]
+		s.data.Current[phase] = math.Round(power * 1000 / s.data.Voltage[phase])
+	}
+
+	s.advanceGas(local, elapsed)
+
+	data := s.data
+	data.SubMeters = append([]SubMeterReading(nil), s.data.SubMeters...)
+	return data
+}
+
+// advanceGas accumulates the gas usage, which is heaviest in the morning and evening.
+// The gas meter only reports its reading every hour.
+func (s *Simulator) advanceGas(local time.Time, elapsed float64) {
+	usage := s.GasPerHour * 0.5
+	if h := local.Hour(); h >= 6 && h < 9 || h >= 17 && h < 23 {
+		usage = s.GasPerHour * 2
+	}
+	s.gas += usage * elapsed * (0.5 + s.rand.Float64())
+
+	gas := &s.data.SubMeters[0]
+	hour := local.Truncate(time.Hour)
+	if gas.CapturedAt.IsZero() || hour.After(gas.CapturedAt) {
+		gas.Value = round(gas.Value+s.gas, 3)
+		gas.CapturedAt = hour
+		s.gas = 0
+	}
+}
+
+// simulatedTarif returns the tarif at the given time: low between 23:00 and 07:00 and during the weekend.
+func simulatedTarif(local time.Time) int {
+	if local.Weekday() == time.Saturday || local.Weekday() == time.Sunday || local.Hour() >= 23 || local.Hour() < 7 {
+		return LowTarif
+	}
+	return PeakTarif
+}
+
+func round(f float64, decimals int) float64 {
+	p := math.Pow(10, float64(decimals))
+	return math.Round(f*p) / p
+}
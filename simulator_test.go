@@ -0,0 +1,81 @@
+package smartmeter
+
+import (
+	"testing"
+	"time"
+)
+
+// TestEncodeTelegram tests that encoded telegrams can be read back.
+func TestEncodeTelegram(t *testing.T) {
+	data := NewSimulator(1).Next(time.Date(2020, 7, 1, 12, 0, 0, 0, meterLocation))
+
+	for _, version := range []DSMRVersion{DSMR22, DSMR4, DSMR5, DSMR5B} {
+		t.Run(string(version), func(t *testing.T) {
+			raw := EncodeTelegram(data, version)
+			if err := VerifyChecksum(raw); version == DSMR22 && err != ErrMissingChecksum {
+				t.Errorf("Expected ErrMissingChecksum, got %v", err)
+			} else if version != DSMR22 && err != nil {
+				t.Fatal(err)
+			}
+
+			r := testReadout(t, raw)
+			if actual, ok := r.MeterTimestamp(); version != DSMR22 && !actual.Equal(data.Timestamp) {
+				t.Errorf("Expected timestamp %s, got %s", data.Timestamp, actual)
+			} else if version == DSMR22 && ok {
+				t.Errorf("Expected no timestamp, got %s", actual)
+			}
+			if actual := valueOf(r.PowerExport()); actual != data.PowerExport {
+				t.Errorf("Expected export %.3f, got %.3f", data.PowerExport, actual)
+			}
+			if actual := valueOf(r.EnergyImport(PeakTarif)); actual != data.EnergyImport[1] {
+				t.Errorf("Expected import %.3f, got %.3f", data.EnergyImport[1], actual)
+			}
+			gas, err := r.SubMeterReading(1)
+			if err != nil || gas.Value != data.SubMeters[0].Value || gas.Unit != data.SubMeters[0].Unit {
+				t.Errorf("Expected gas %.3f %s, got %+v (%v)", data.SubMeters[0].Value, data.SubMeters[0].Unit, gas, err)
+			}
+			if !gas.CapturedAt.Equal(data.SubMeters[0].CapturedAt) {
+				t.Errorf("Expected gas captured at %s, got %s", data.SubMeters[0].CapturedAt, gas.CapturedAt)
+			}
+			if actual := r.EquipmentID(); actual != data.EquipmentID {
+				t.Errorf("Expected equipment id %s, got %s", data.EquipmentID, actual)
+			}
+		})
+	}
+}
+
+// TestSimulator tests that the simulation is reproducible and plausible.
+func TestSimulator(t *testing.T) {
+	a, b := NewSimulator(42), NewSimulator(42)
+	start := time.Date(2020, 7, 1, 0, 0, 0, 0, meterLocation)
+
+	previous := a.Next(start)
+	b.Next(start)
+	for ts := start.Add(time.Minute); ts.Before(start.Add(24 * time.Hour)); ts = ts.Add(time.Minute) {
+		current := a.Next(ts)
+		if EncodeTelegram(current, DSMR5) != b.Telegram(ts) {
+			t.Fatalf("Simulators with the same seed differ at %s", ts)
+		}
+
+		for i := range current.EnergyImport {
+			if current.EnergyImport[i] < previous.EnergyImport[i] || current.EnergyExport[i] < previous.EnergyExport[i] {
+				t.Fatalf("Totals decreased at %s", ts)
+			}
+		}
+		if current.SubMeters[0].Value < previous.SubMeters[0].Value {
+			t.Fatalf("Gas decreased at %s", ts)
+		}
+		if current.SubMeters[0].CapturedAt.Minute() != 0 {
+			t.Fatalf("Gas captured at %s instead of a full hour", current.SubMeters[0].CapturedAt)
+		}
+
+		expectedTarif := PeakTarif
+		if ts.Hour() < 7 || ts.Hour() >= 23 {
+			expectedTarif = LowTarif
+		}
+		if current.Tarif != expectedTarif {
+			t.Fatalf("Expected tarif %d at %s, got %d", expectedTarif, ts, current.Tarif)
+		}
+		previous = current
+	}
+}
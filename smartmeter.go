@@ -2,53 +2,167 @@ package smartmeter
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"log"
+	"sync"
+	"sync/atomic"
 	"time"
-
-	"github.com/tarm/serial"
-
-	"github.com/roaldnefs/go-dsmr"
 )
 
 // AllowSerialPortFailure adds the option to continue if the serial connection fails.
+//
+// Deprecated: the serial port is reopened until it succeeds, so the connection failing is never fatal.
 var AllowSerialPortFailure = false
 
-// ReadTelegrams reads telegrams from the given serial port into the given readout channel.
-func ReadTelegrams(serialPort string, rChan chan Readout) {
+// ReconnectMinDelay is the delay before the first attempt to reopen the serial port after it failed.
+var ReconnectMinDelay = time.Second
+
+// ReconnectMaxDelay is the maximum delay between attempts to reopen the serial port.
+var ReconnectMaxDelay = time.Minute
+
+// ConnectionState describes the state of the connection to the P1 port.
+type ConnectionState int
+
+const (
+	// Disconnected indicates that the serial port is closed.
+	Disconnected ConnectionState = iota
+	// Connecting indicates that the serial port is being opened.
+	Connecting
+	// Connected indicates that the serial port is open.
+	Connected
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case Connecting:
+		return "connecting"
+	case Connected:
+		return "connected"
+	}
+	return "disconnected"
+}
+
+// ReadOption changes how telegrams are read.
+type ReadOption func(*readOptions)
+
+type readOptions struct {
+	recorder *Recorder
+}
+
+// WithRecorder records every valid raw telegram with the given recorder.
+func WithRecorder(recorder *Recorder) ReadOption {
+	return func(o *readOptions) {
+		o.recorder = recorder
+	}
+}
+
+// ReadTelegrams reads telegrams from the given source into the given readout channel.
+func ReadTelegrams(source TelegramSource, rChan chan Readout, options ...ReadOption) {
+	ReadTelegramsContext(context.Background(), source, rChan, options...)
+}
+
+// ReadTelegramsContext reads telegrams from the given source into the given readout channel until the context is done
+// or the source is exhausted. The source is released and the readout channel is closed before it returns the error of
+// the context.
+func ReadTelegramsContext(ctx context.Context, source TelegramSource, rChan chan Readout, options ...ReadOption) error {
+	defer close(rChan)
+
+	var o readOptions
+	for _, option := range options {
+		option(&o)
+	}
+
 	lineChan := make(chan string)
 	rawTelegramChan := make(chan string)
-	go readLines(serialPort, lineChan)
-	go collectTelegrams(lineChan, rawTelegramChan)
-	parseTelegrams(rawTelegramChan, rChan)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		defer close(lineChan)
+		readLines(ctx, source, lineChan)
+	}()
+	go func() {
+		defer wg.Done()
+		defer close(rawTelegramChan)
+		collectTelegrams(ctx, lineChan, rawTelegramChan, o.recorder)
+	}()
+	parseTelegrams(ctx, rawTelegramChan, rChan)
+	wg.Wait()
+
+	return ctx.Err()
 }
 
-func readLines(serialPort string, rChan chan string) {
-	c := &serial.Config{
-		Name: serialPort,
-		Baud: 115200,
-	}
+// readLines reads lines from the source and reopens the source with an exponential backoff whenever it fails.
+func readLines(ctx context.Context, source TelegramSource, rChan chan string) {
+	delay := ReconnectMinDelay
+	for {
+		connected, err := readLinesFromSource(ctx, source, rChan)
+		stateChanged(source, Disconnected, err)
+		if ctx.Err() != nil || errors.Is(err, ErrSourceExhausted) {
+			return
+		}
+		if connected {
+			delay = ReconnectMinDelay
+		}
 
-	s, err := serial.OpenPort(c)
-	if err != nil {
-		if AllowSerialPortFailure {
+		log.Println("Telegram source failed:", err, "reconnecting in", delay)
+		select {
+		case <-ctx.Done():
 			return
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > ReconnectMaxDelay {
+			delay = ReconnectMaxDelay
 		}
-		log.Fatal(err)
 	}
+}
+
+// readLinesFromSource opens the source and reads lines from it until reading fails.
+// It reports whether the source had been opened successfully along with the error that caused it to stop.
+func readLinesFromSource(ctx context.Context, source TelegramSource, rChan chan string) (bool, error) {
+	stateChanged(source, Connecting, nil)
+
+	s, err := source.Open(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer s.Close()
+	stateChanged(source, Connected, nil)
+
+	// Closing the source interrupts a blocking read of network connections and pipes. Serial ports can't be
+	// interrupted, their reads time out instead and stop once the context is done.
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.Close()
+		case <-stop:
+		}
+	}()
 
 	reader := bufio.NewReader(s)
 	for {
 		reply, err := reader.ReadString('\n')
-		if err != nil {
-			log.Println(err)
-			continue
+		if reply != "" {
+			select {
+			case rChan <- reply:
+			case <-ctx.Done():
+				return true, ctx.Err()
+			}
 		}
 
-		rChan <- reply
+		if err != nil {
+			return true, err
+		}
 	}
 }
 
-func collectTelegrams(rChan chan string, tChan chan string) {
+func collectTelegrams(ctx context.Context, rChan chan string, tChan chan string, recorder *Recorder) {
 	telegram := ""
 	foundStart := false
 
@@ -67,21 +181,49 @@ func collectTelegrams(rChan chan string, tChan chan string) {
 
 		telegram += line
 
-		// The last line of a telegram starts with an exclamation mark.
-		if line[0] == '!' {
-			tChan <- telegram
+		// The last line of a telegram starts with an exclamation mark, followed by its checksum.
+		if line[0] != '!' || !validTelegram(telegram) {
+			continue
+		}
+
+		if recorder != nil {
+			if err := recorder.Record(telegram); err != nil {
+				log.Println("Recording telegram failed:", err)
+			}
+		}
+
+		select {
+		case tChan <- telegram:
+		case <-ctx.Done():
+			return
 		}
 	}
 }
 
-func parseTelegrams(rawTelegramChan chan string, rChan chan Readout) {
+// validTelegram verifies the checksum of a telegram and counts the telegram as corrupt if it is invalid.
+func validTelegram(telegram string) bool {
+	err := VerifyChecksum(telegram)
+	if err == nil || (err == ErrMissingChecksum && AllowMissingChecksum) {
+		return true
+	}
+
+	atomic.AddUint64(&corruptTelegrams, 1)
+	log.Println("Dropping telegram:", err)
+	return false
+}
+
+func parseTelegrams(ctx context.Context, rawTelegramChan chan string, rChan chan Readout) {
 	for t := range rawTelegramChan {
-		telegram, err := dsmr.ParseTelegram(t)
+		telegram, err := parseTelegram(t)
 		if err != nil {
 			log.Println(err)
 			continue
 		}
 
-		rChan <- Readout{time.Now(), telegram}
+		select {
+		case rChan <- Readout{Timestamp: time.Now(), telegram: telegram, raw: t}:
+		case <-ctx.Done():
+			return
+		}
 	}
 }
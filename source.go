@@ -0,0 +1,125 @@
+package smartmeter
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"time"
+)
+
+// ErrSourceExhausted is returned by a TelegramSource that has no more telegrams to provide.
+var ErrSourceExhausted = errors.New("telegram source exhausted")
+
+// TelegramSource provides the raw P1 output of a meter.
+type TelegramSource interface {
+	// Open opens the source for reading. It is called again to reopen the source whenever reading fails.
+	// The returned reader is closed to interrupt reading when the context is done.
+	Open(ctx context.Context) (io.ReadCloser, error)
+}
+
+// stateReporter is implemented by telegram sources which report the state of their connection.
+type stateReporter interface {
+	stateChanged(state ConnectionState, err error)
+}
+
+func stateChanged(source TelegramSource, state ConnectionState, err error) {
+	if r, ok := source.(stateReporter); ok {
+		r.stateChanged(state, err)
+	}
+}
+
+// TCPSource reads telegrams from a TCP stream, such as provided by ser2net or network P1 dongles.
+type TCPSource struct {
+	// Address is the host:port to connect to.
+	Address     string
+	DialTimeout time.Duration
+	// ReadTimeout is the maximum time to wait for data before the connection is considered dead.
+	ReadTimeout time.Duration
+	// StateChanged is called whenever the state of the connection changes.
+	// The error contains the reason of the state change, if any.
+	StateChanged func(state ConnectionState, err error)
+}
+
+// NewTCPSource creates a new TCPSource for the given host:port.
+func NewTCPSource(address string) *TCPSource {
+	return &TCPSource{
+		Address:     address,
+		DialTimeout: time.Second * 10,
+		ReadTimeout: time.Minute,
+	}
+}
+
+// Open connects to the TCP stream.
+func (t *TCPSource) Open(ctx context.Context) (io.ReadCloser, error) {
+	dialer := net.Dialer{Timeout: t.DialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", t.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.ReadTimeout == 0 {
+		return conn, nil
+	}
+	return &deadlineConn{conn, t.ReadTimeout}, nil
+}
+
+func (t *TCPSource) stateChanged(state ConnectionState, err error) {
+	if t.StateChanged != nil {
+		t.StateChanged(state, err)
+	}
+}
+
+// deadlineConn fails reads that take longer than the timeout.
+type deadlineConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *deadlineConn) Read(b []byte) (int, error) {
+	c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+	return c.Conn.Read(b)
+}
+
+// ReaderSource reads telegrams from an io.Reader, such as a file or a buffer.
+// The source is exhausted once the reader returns io.EOF.
+type ReaderSource struct {
+	reader io.Reader
+	opened bool
+}
+
+// NewReaderSource creates a new ReaderSource for the given reader.
+func NewReaderSource(r io.Reader) *ReaderSource {
+	return &ReaderSource{reader: r}
+}
+
+// Open returns the reader. A reader can only be opened once.
+func (r *ReaderSource) Open(ctx context.Context) (io.ReadCloser, error) {
+	if r.opened {
+		return nil, ErrSourceExhausted
+	}
+	r.opened = true
+
+	return &exhaustibleReader{r.reader}, nil
+}
+
+// exhaustibleReader reports the end of the reader as ErrSourceExhausted.
+type exhaustibleReader struct {
+	reader io.Reader
+}
+
+func (e *exhaustibleReader) Read(b []byte) (int, error) {
+	n, err := e.reader.Read(b)
+	if err == io.EOF {
+		err = ErrSourceExhausted
+	}
+	return n, err
+}
+
+// Close closes the underlying reader if it is an io.Closer.
+func (e *exhaustibleReader) Close() error {
+	if c, ok := e.reader.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package smartmeter
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestDeadlineConn tests that every read from a TCP stream fails when no data arrives within the timeout.
+func TestDeadlineConn(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	c := &deadlineConn{client, 20 * time.Millisecond}
+	defer c.Close()
+
+	b := make([]byte, 16)
+	if _, err := c.Read(b); err == nil {
+		t.Fatal("Expected the read to time out")
+	} else if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+		t.Fatalf("Expected a timeout, got %v", err)
+	}
+
+	// The deadline is extended by every read, so data arriving after the first deadline is read.
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		server.Write([]byte("/ISK5\r\n"))
+	}()
+	if n, err := c.Read(b); err != nil || string(b[:n]) != "/ISK5\r\n" {
+		t.Errorf("Expected to read the header, got %q (%v)", b[:n], err)
+	}
+}
+
+// openCounter counts how often its source is opened.
+type openCounter struct {
+	TelegramSource
+	opened int
+}
+
+func (c *openCounter) Open(ctx context.Context) (io.ReadCloser, error) {
+	c.opened++
+	return c.TelegramSource.Open(ctx)
+}
+
+// TestReaderSourceExhausted tests that reading stops without reconnecting once a reader source is exhausted.
+func TestReaderSourceExhausted(t *testing.T) {
+	delay := ReconnectMinDelay
+	ReconnectMinDelay = time.Hour
+	defer func() { ReconnectMinDelay = delay }()
+
+	telegram := NewSimulator(1).Telegram(time.Date(2020, 7, 1, 12, 0, 0, 0, meterLocation))
+	source := &openCounter{TelegramSource: NewReaderSource(strings.NewReader(telegram))}
+	rChan := make(chan Readout)
+	errChan := make(chan error)
+	go func() { errChan <- ReadTelegramsContext(context.Background(), source, rChan) }()
+
+	readouts := 0
+	for range rChan {
+		readouts++
+	}
+	select {
+	case err := <-errChan:
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected reading to stop once the source is exhausted")
+	}
+	if readouts != 1 || source.opened != 1 {
+		t.Errorf("Expected a single readout from a single open, got %d readouts from %d opens", readouts, source.opened)
+	}
+}
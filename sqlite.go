@@ -0,0 +1,40 @@
+package smartmeter
+
+import "strings"
+
+var sqliteDialect = sqlDialect{
+	driver:   "sqlite",
+	idColumn: primaryKey("id INTEGER PRIMARY KEY AUTOINCREMENT"),
+	setup: []string{
+		"PRAGMA journal_mode=WAL",
+		"PRAGMA busy_timeout=5000",
+	},
+	// SQLite only allows a single writer and every connection to :memory: opens a new, empty database.
+	maxOpenConns: 1,
+	// An immediate transaction takes the write lock of the database right away.
+	lockSchema:        "BEGIN IMMEDIATE",
+	unlockSchema:      "COMMIT",
+	schemaTransaction: true,
+	ignoreDuplicate: func(insert string) string {
+		return strings.Replace(insert, "INSERT INTO", "INSERT OR IGNORE INTO", 1)
+	},
+	updateDuplicate: updateOnConflict,
+	// SQLite can't change the type of a column, but it stores values by their affinity anyway, so columns are only renamed.
+	alterColumns: func(table string, changes []columnChange) []string {
+		var statements []string
+		for _, c := range changes {
+			if c.name != c.column {
+				statements = append(statements, "ALTER TABLE "+table+" RENAME COLUMN "+c.column+" TO "+c.name)
+			}
+		}
+		return statements
+	},
+}
+
+// NewSQLite creates a storage backend which stores the readouts in the SQLite database at the given path, which is
+// created when it doesn't exist. It uses the same schema and queries as the MySQL backend, which makes it a lightweight
+// alternative for e.g. a Raspberry Pi. The path :memory: creates a database which only lives as long as the backend.
+// The application has to register the sqlite driver, e.g. by importing modernc.org/sqlite.
+func NewSQLite(path string) *SQL {
+	return &SQL{Database: path, dialect: &sqliteDialect}
+}
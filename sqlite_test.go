@@ -0,0 +1,78 @@
+package smartmeter
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// TestSQLite tests storing readouts in an SQLite database like every other storage backend.
+func TestSQLite(t *testing.T) {
+	s := NewSQLite(filepath.Join(t.TempDir(), "smartmeter.db"))
+	s.Timestamps = MeterTime
+	defer s.Close()
+	testStorage(t, s)
+
+	var mode string
+	if err := s.db.QueryRow("PRAGMA journal_mode").Scan(&mode); err != nil || mode != "wal" {
+		t.Errorf("Expected journal mode wal, got %s (%v)", mode, err)
+	}
+
+	var meters int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM meters").Scan(&meters); err != nil || meters != 2 {
+		t.Errorf("Expected the meter and gas meter to be registered once, got %d (%v)", meters, err)
+	}
+}
+
+// TestSQLiteConcurrentInserts tests that readouts and power quality counters can be inserted concurrently.
+func TestSQLiteConcurrentInserts(t *testing.T) {
+	s := NewSQLite(":memory:")
+	s.Timestamps = MeterTime
+	defer s.Close()
+
+	ctx := context.Background()
+	start := time.Date(2020, 7, 1, 12, 0, 0, 0, time.Local)
+	readout := testReadout(t, EncodeTelegram(NewSimulator(1).Next(start), DSMR5))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.Insert(ctx, readout); err != nil {
+				t.Error(err)
+			}
+			if err := s.InsertPowerQuality(ctx, readout); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	counters, _, err := s.GetPowerQualityRange(ctx, start, start.Add(time.Hour))
+	if err != nil || len(counters) != 1 {
+		t.Errorf("Expected a single set of counters, got %+v (%v)", counters, err)
+	}
+}
+
+// TestSQLiteCorruptRow tests that retrieving a row which can't be read fails, rather than leaving the row out.
+func TestSQLiteCorruptRow(t *testing.T) {
+	s := NewSQLite(":memory:")
+	s.Timestamps = MeterTime
+	defer s.Close()
+
+	ctx := context.Background()
+	start := time.Date(2020, 7, 1, 12, 0, 0, 0, time.Local)
+	insertSimulated(t, s, start, 2)
+	if _, err := s.db.Exec("UPDATE readouts SET power_received='corrupt' WHERE timestamp=?", "2020-07-01 12:01:00"); err != nil {
+		t.Fatal(err)
+	}
+
+	if readouts, err := s.GetRange(ctx, start, start.Add(time.Hour), Power); err == nil {
+		t.Errorf("Expected an error, got %+v", readouts)
+	}
+}
@@ -0,0 +1,219 @@
+package smartmeter
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// insertSimulated inserts a simulated readout every minute from the given start into the storage.
+func insertSimulated(t *testing.T, s Storage, start time.Time, minutes int) []TelegramData {
+	simulator := NewSimulator(1)
+	var inserted []TelegramData
+	for i := 0; i < minutes; i++ {
+		data := simulator.Next(start.Add(time.Duration(i) * time.Minute))
+		if err := s.Insert(context.Background(), testReadout(t, EncodeTelegram(data, DSMR5))); err != nil {
+			t.Fatal(err)
+		}
+		inserted = append(inserted, data)
+	}
+	return inserted
+}
+
+// testStorage tests the behaviour every storage backend has in common. The storage has to be empty, store the
+// timestamps of the meter and keep at least a day of readouts.
+func testStorage(t *testing.T, s Storage) {
+	t.Run("Readouts", func(t *testing.T) { testStorageReadouts(t, s) })
+	t.Run("MissingValues", func(t *testing.T) { testStorageMissingValues(t, s) })
+	t.Run("GasReadings", func(t *testing.T) { testStorageGasReadings(t, s) })
+	t.Run("PowerQualityAndMessages", func(t *testing.T) { testStoragePowerQualityAndMessages(t, s) })
+	t.Run("PowerQualityReport", func(t *testing.T) { testStoragePowerQualityReport(t, s) })
+}
+
+// testStorageReadouts tests storing and retrieving readouts.
+func testStorageReadouts(t *testing.T, s Storage) {
+	ctx := context.Background()
+	start := time.Date(2020, 7, 1, 12, 0, 0, 0, time.Local)
+	end := start.Add(time.Hour)
+	inserted := insertSimulated(t, s, start, 60)
+
+	all, err := s.GetRange(ctx, start, end, All)
+	if err != nil || len(all) != 60 {
+		t.Fatalf("Expected 60 readouts, got %d (%v)", len(all), err)
+	}
+	last := inserted[59]
+	r := all[59]
+	if r.Timestamp != "2020-07-01 12:59:00" {
+		t.Errorf("Expected timestamp 2020-07-01 12:59:00, got %s", r.Timestamp)
+	}
+	if r.Tarif != last.Tarif || r.PowerDelivered != last.PowerExport || r.TotalPowerReceivedPeakTarif != last.EnergyImport[1] {
+		t.Errorf("Expected tarif %d, export %.3f and import %.3f, got %+v", last.Tarif, last.PowerExport, last.EnergyImport[1], r)
+	}
+	if r.GasReceived != last.SubMeters[0].Value || r.VoltageL2 != last.Voltage[1] {
+		t.Errorf("Expected gas %.3f and voltage %.1f, got %+v", last.SubMeters[0].Value, last.Voltage[1], r)
+	}
+
+	power, err := s.GetRange(ctx, start.Add(30*time.Minute), end, Power)
+	if err != nil || len(power) != 30 {
+		t.Fatalf("Expected 30 readouts, got %d (%v)", len(power), err)
+	}
+	if r := power[29]; r.PowerDelivered != last.PowerExport || r.GasReceived != 0 || r.VoltageL1 != 0 {
+		t.Errorf("Expected only the power to be retrieved, got %+v", r)
+	}
+
+	gas, err := s.GetRange(ctx, start, end, Gas)
+	if err != nil || len(gas) != 1 || gas[0].GasReceived != last.SubMeters[0].Value {
+		t.Errorf("Expected a single gas reading of %.3f, got %+v (%v)", last.SubMeters[0].Value, gas, err)
+	}
+
+	averaged, err := s.GetAveragedRange(ctx, start, end, 10*time.Minute, Power)
+	if err != nil || len(averaged) != 6 {
+		t.Fatalf("Expected 6 averaged readouts, got %d (%v)", len(averaged), err)
+	}
+	if averaged[1].Timestamp != "2020-07-01 12:10:00" {
+		t.Errorf("Expected the second average to start at 2020-07-01 12:10:00, got %s", averaged[1].Timestamp)
+	}
+}
+
+// testStorageMissingValues tests that datapoints which are missing from a readout are retrieved as missing, rather
+// than as 0, and are left out of averages.
+func testStorageMissingValues(t *testing.T, s Storage) {
+	ctx := context.Background()
+	simulator := NewSimulator(1)
+	start := time.Date(2020, 7, 1, 14, 0, 0, 0, time.Local)
+
+	// The simulated household has no water meter, and the gas meter is missing from the second readout.
+	complete := simulator.Next(start)
+	partial := simulator.Next(start.Add(time.Minute))
+	partial.SubMeters = nil
+	last := simulator.Next(start.Add(2 * time.Minute))
+	last.SubMeters = complete.SubMeters
+	for _, data := range []TelegramData{complete, partial, last} {
+		if err := s.Insert(ctx, testReadout(t, EncodeTelegram(data, DSMR5))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	readouts, err := s.GetRange(ctx, start, start.Add(time.Hour), All)
+	if err != nil || len(readouts) != 3 {
+		t.Fatalf("Expected 3 readouts, got %d (%v)", len(readouts), err)
+	}
+	if r := readouts[1]; !r.Missing("GasReceived") || !r.Missing("WaterReceived") || r.Missing("PowerReceived") || r.Missing("Tarif") {
+		t.Errorf("Expected only the gas and water to be missing, got %+v", r)
+	}
+	if csv := string(ReadoutsToCSV(readouts[1:2], Gas+Power)); !strings.Contains(csv, "2020-07-01 14:01:00,,") {
+		t.Errorf("Expected the missing gas to be exported as an empty value, got %s", csv)
+	}
+	if json := string(ReadoutsToJSON(readouts[1:2], All)); !strings.Contains(json, `"GasReceived":null`) {
+		t.Errorf("Expected the missing gas to be exported as null, got %s", json)
+	}
+
+	averaged, err := s.GetAveragedRange(ctx, start, start.Add(time.Hour), time.Hour, All)
+	if err != nil || len(averaged) != 1 {
+		t.Fatalf("Expected a single average, got %d (%v)", len(averaged), err)
+	}
+	if r := averaged[0]; r.GasReceived != complete.SubMeters[0].Value || !r.Missing("WaterReceived") {
+		t.Errorf("Expected an average gas of %.3f without water, got %+v", complete.SubMeters[0].Value, r)
+	}
+}
+
+// testStorageGasReadings tests that every gas reading is retrieved once, at the first readout which contains it, even
+// when readouts without the gas meter are received in between.
+func testStorageGasReadings(t *testing.T, s Storage) {
+	ctx := context.Background()
+	simulator := NewSimulator(1)
+	start := time.Date(2020, 7, 1, 18, 0, 0, 0, time.Local)
+
+	// The meter repeats the reading of 18:00 until a new reading arrives at 18:06, and misses the gas meter at 18:02.
+	var first, second SubMeterReading
+	for i := 0; i < 8; i++ {
+		data := simulator.Next(start.Add(time.Duration(i) * time.Minute))
+		switch {
+		case i == 0:
+			first = data.SubMeters[0]
+		case i == 2:
+			data.SubMeters = nil
+		case i < 6:
+			data.SubMeters[0] = first
+		case i == 6:
+			second = data.SubMeters[0]
+			second.Value = first.Value + 0.5
+			data.SubMeters[0] = second
+		default:
+			data.SubMeters[0] = second
+		}
+		if err := s.Insert(ctx, testReadout(t, EncodeTelegram(data, DSMR5))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gas, err := s.GetRange(ctx, start, start.Add(time.Hour), Gas)
+	if err != nil || len(gas) != 2 {
+		t.Fatalf("Expected 2 gas readings, got %+v (%v)", gas, err)
+	}
+	if gas[0].Timestamp != "2020-07-01 18:00:00" || gas[0].GasReceived != first.Value {
+		t.Errorf("Expected %.3f at 2020-07-01 18:00:00, got %+v", first.Value, gas[0])
+	}
+	if gas[1].Timestamp != "2020-07-01 18:06:00" || gas[1].GasReceived != second.Value {
+		t.Errorf("Expected %.3f at 2020-07-01 18:06:00, got %+v", second.Value, gas[1])
+	}
+}
+
+// testStoragePowerQualityAndMessages tests that power quality counters and messages are only stored when they change.
+func testStoragePowerQualityAndMessages(t *testing.T, s Storage) {
+	ctx := context.Background()
+	simulator := NewSimulator(1)
+	start := time.Date(2020, 7, 1, 16, 0, 0, 0, time.Local)
+
+	for i, failures := range []int64{1, 1, 2} {
+		data := simulator.Next(start.Add(time.Duration(i) * time.Minute))
+		data.PowerFailures = failures
+		data.Message = "Storing"
+		readout := testReadout(t, EncodeTelegram(data, DSMR5))
+
+		if err := s.InsertPowerQuality(ctx, readout); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.InsertMessages(ctx, readout); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	counters, _, err := s.GetPowerQualityRange(ctx, start, start.Add(time.Hour))
+	if err != nil || len(counters) != 2 || counters[1].PowerFailures != 2 {
+		t.Errorf("Expected 2 sets of counters, got %+v (%v)", counters, err)
+	}
+
+	messages, err := s.GetMessages(ctx, start, start.Add(time.Hour))
+	if err != nil || len(messages) != 1 {
+		t.Fatalf("Expected a single message, got %+v (%v)", messages, err)
+	}
+	if m := messages[0]; m.Text != "Storing" || !m.FirstSeen.Equal(start) || !m.LastSeen.Equal(start.Add(2*time.Minute)) {
+		t.Errorf("Expected the message to be seen from %s until %s, got %+v", start, start.Add(2*time.Minute), m)
+	}
+}
+
+// testStoragePowerQualityReport tests that the monthly report counts the events between the last counters before the
+// report and the first counters within it.
+func testStoragePowerQualityReport(t *testing.T, s Storage) {
+	ctx := context.Background()
+	simulator := NewSimulator(1)
+	august := time.Date(2020, 8, 1, 0, 0, 0, 0, time.Local)
+
+	for i, failures := range []int64{5, 7, 8} {
+		data := simulator.Next(august.Add(time.Duration(i-1) * time.Hour))
+		data.PowerFailures = failures
+		if err := s.InsertPowerQuality(ctx, testReadout(t, EncodeTelegram(data, DSMR5))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reports, err := s.GetPowerQualityReport(ctx, august, august.AddDate(0, 1, 0))
+	if err != nil || len(reports) != 1 {
+		t.Fatalf("Expected a single report, got %+v (%v)", reports, err)
+	}
+	if r := reports[0]; !r.Month.Equal(august) || r.PowerFailures != 3 {
+		t.Errorf("Expected 3 power failures in August, got %+v", r)
+	}
+}
@@ -0,0 +1,108 @@
+package smartmeter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dateTimeLayouts contains the supported layouts of inputs containing both a date and a time.
+var dateTimeLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+}
+
+// timeLayouts contains the supported layouts of inputs containing only a time.
+var timeLayouts = []string{"15:04:05", "15:04"}
+
+// StringToTime converts a string to a time in the given location. It accepts:
+//   - a date and time, e.g. "2020-02-03 13:22:33" or ISO 8601 "2020-02-03T13:22:33+01:00"
+//   - a date, e.g. "2020-02-03", which is combined with the default time
+//   - a time, e.g. "13:22:33", which is combined with today's date
+//   - a duration relative to now, e.g. "-24h", "-90m" or "-7d"
+//   - "now", "today", "yesterday" or "tomorrow", of which the latter three are combined with the default time
+//
+// Today's date combined with the default time is returned for empty input, as well as along with an error for
+// input that could not be parsed.
+func StringToTime(input string, loc *time.Location, defaultTime string) (time.Time, error) {
+	return stringToTime(input, loc, defaultTime, time.Now())
+}
+
+// stringToTime converts a string to a time like StringToTime, relative to the given time rather than now.
+func stringToTime(input string, loc *time.Location, defaultTime string, now time.Time) (time.Time, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+
+	now = now.In(loc)
+	today := now.Format("2006-01-02")
+	fallback, err := parseDateTime(today+" "+defaultTime, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid default time %q", defaultTime)
+	}
+
+	input = strings.TrimSpace(input)
+	switch strings.ToLower(input) {
+	case "", "today":
+		return fallback, nil
+	case "now":
+		return now, nil
+	case "yesterday":
+		return fallback.AddDate(0, 0, -1), nil
+	case "tomorrow":
+		return fallback.AddDate(0, 0, 1), nil
+	}
+
+	if t, ok := parseRelativeTime(input, now); ok {
+		return t, nil
+	}
+
+	if t, err := parseDateTime(input, loc); err == nil {
+		return t, nil
+	}
+
+	if t, err := time.ParseInLocation("2006-01-02", input, loc); err == nil {
+		return parseDateTime(t.Format("2006-01-02")+" "+defaultTime, loc)
+	}
+
+	for _, layout := range timeLayouts {
+		if _, err := time.Parse(layout, input); err == nil {
+			return time.ParseInLocation("2006-01-02 "+layout, today+" "+input, loc)
+		}
+	}
+
+	return fallback, fmt.Errorf("unable to parse time %q", input)
+}
+
+// parseDateTime parses an input containing both a date and a time in one of the dateTimeLayouts.
+func parseDateTime(input string, loc *time.Location) (time.Time, error) {
+	var err error
+	for _, layout := range dateTimeLayouts {
+		var t time.Time
+		if t, err = time.ParseInLocation(layout, input, loc); err == nil {
+			return t.In(loc), nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// parseRelativeTime parses a duration relative to now, which may be expressed in days using the d unit.
+func parseRelativeTime(input string, now time.Time) (time.Time, bool) {
+	if strings.HasSuffix(input, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(input, "d"))
+		if err != nil {
+			return time.Time{}, false
+		}
+		return now.AddDate(0, 0, days), true
+	}
+
+	d, err := time.ParseDuration(input)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return now.Add(d), true
+}
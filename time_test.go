@@ -0,0 +1,32 @@
+package smartmeter
+
+import (
+	"testing"
+	"time"
+)
+
+// TestStringToTimeRelative tests converting times which are relative to now. Now is just before midnight, so the
+// expectations only hold when every part of the conversion uses the same reference time.
+func TestStringToTimeRelative(t *testing.T) {
+	now := time.Date(2020, 7, 1, 23, 59, 59, 0, meterLocation)
+
+	cases := []struct {
+		input    string
+		expected time.Time
+	}{
+		{"13:22:33", time.Date(2020, 7, 1, 13, 22, 33, 0, meterLocation)},
+		{"", time.Date(2020, 7, 1, 1, 2, 3, 0, meterLocation)},
+		{"lsbhewr", time.Date(2020, 7, 1, 1, 2, 3, 0, meterLocation)},
+		{"today", time.Date(2020, 7, 1, 1, 2, 3, 0, meterLocation)},
+		{"yesterday", time.Date(2020, 6, 30, 1, 2, 3, 0, meterLocation)},
+		{"tomorrow", time.Date(2020, 7, 2, 1, 2, 3, 0, meterLocation)},
+		{"now", now},
+		{"-90m", now.Add(-90 * time.Minute)},
+		{"-7d", time.Date(2020, 6, 24, 23, 59, 59, 0, meterLocation)},
+	}
+	for _, c := range cases {
+		if actual, _ := stringToTime(c.input, meterLocation, "01:02:03", now); !actual.Equal(c.expected) {
+			t.Errorf("Expected %q to be %s, got %s", c.input, c.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package smartmeter
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// VirtualPort is a pseudo-terminal which behaves like the P1 port of a meter, so the full stack can be tested without
+// hardware. Its Path can be opened like any serial port, e.g. by passing DSMR4SerialConfig(port.Path) to ReadTelegrams.
+type VirtualPort struct {
+	// Path is the path of the device to read the telegrams from.
+	Path string
+
+	master *os.File
+	slave  *os.File
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewVirtualPort creates a pseudo-terminal and writes a telegram of the simulator to it every interval, starting
+// immediately. The interval must be positive. A port without a simulator stays silent, like a meter which doesn't send
+// any telegrams.
+func NewVirtualPort(simulator *Simulator, interval time.Duration) (*VirtualPort, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	path, err := unlockPty(master)
+	if err != nil {
+		master.Close()
+		return nil, err
+	}
+
+	// The slave is kept open, so the terminal keeps its raw mode while readers come and go.
+	slave, err := os.OpenFile(path, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, err
+	}
+	if err := makeRaw(slave); err != nil {
+		slave.Close()
+		master.Close()
+		return nil, err
+	}
+
+	p := &VirtualPort{Path: path, master: master, slave: slave, done: make(chan struct{})}
+	p.wg.Add(1)
+	go p.writeTelegrams(simulator, interval)
+	return p, nil
+}
+
+// Close stops writing telegrams and removes the pseudo-terminal.
+func (p *VirtualPort) Close() error {
+	close(p.done)
+	err := p.master.Close()
+	p.wg.Wait()
+	p.slave.Close()
+	return err
+}
+
+func (p *VirtualPort) writeTelegrams(simulator *Simulator, interval time.Duration) {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if simulator == nil {
+			<-p.done
+			return
+		}
+		if _, err := p.master.WriteString(simulator.Telegram(time.Now())); err != nil {
+			select {
+			case <-p.done:
+			default:
+				log.Println("Writing to virtual port failed:", err)
+			}
+			return
+		}
+
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// unlockPty unlocks the slave of the pseudo-terminal and returns its path.
+func unlockPty(master *os.File) (string, error) {
+	unlock := 0
+	if err := ioctl(master, syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); err != nil {
+		return "", fmt.Errorf("unlocking pty: %w", err)
+	}
+
+	var n uint32
+	if err := ioctl(master, syscall.TIOCGPTN, uintptr(unsafe.Pointer(&n))); err != nil {
+		return "", fmt.Errorf("getting pty number: %w", err)
+	}
+	return fmt.Sprintf("/dev/pts/%d", n), nil
+}
+
+// makeRaw disables the line discipline of the terminal, so telegrams are passed through byte for byte.
+// Otherwise carriage returns would be translated into newlines, which invalidates the checksum.
+func makeRaw(f *os.File) error {
+	var t syscall.Termios
+	if err := ioctl(f, syscall.TCGETS, uintptr(unsafe.Pointer(&t))); err != nil {
+		return err
+	}
+
+	t.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR |
+		syscall.ICRNL | syscall.IXON
+	t.Oflag &^= syscall.OPOST
+	t.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	t.Cflag &^= syscall.CSIZE | syscall.PARENB
+	t.Cflag |= syscall.CS8
+	t.Cc[syscall.VMIN] = 1
+	t.Cc[syscall.VTIME] = 0
+
+	return ioctl(f, syscall.TCSETS, uintptr(unsafe.Pointer(&t)))
+}
+
+func ioctl(f *os.File, request, arg uintptr) error {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var errno syscall.Errno
+	err = conn.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, request, arg)
+	})
+	if err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
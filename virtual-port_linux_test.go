@@ -0,0 +1,199 @@
+package smartmeter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestVirtualPort tests that telegrams written to a virtual port are read like telegrams of a real meter.
+func TestVirtualPort(t *testing.T) {
+	port, err := NewVirtualPort(NewSimulator(1), 50*time.Millisecond)
+	if err != nil {
+		t.Skip("Pseudo-terminals are unavailable:", err)
+	}
+	defer port.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	config := DSMR4SerialConfig(port.Path)
+	rChan := make(chan Readout)
+	errChan := make(chan error)
+	go func() { errChan <- ReadTelegramsContext(ctx, &config, rChan) }()
+
+	corrupt := CorruptTelegrams()
+	for i := 0; i < 3; i++ {
+		r, ok := <-rChan
+		if !ok {
+			t.Fatal("Expected readouts, but the readout channel was closed:", <-errChan)
+		}
+		if _, err := r.PowerImport(); err != nil {
+			t.Error("Expected power import:", err)
+		}
+		if r.EquipmentID() != "E0043007000000000" {
+			t.Errorf("Expected equipment id E0043007000000000, got %s", r.EquipmentID())
+		}
+	}
+	if actual := CorruptTelegrams(); actual != corrupt {
+		t.Errorf("Expected no corrupt telegrams, got %d", actual-corrupt)
+	}
+
+	cancel()
+	for range rChan {
+	}
+	if err := <-errChan; err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+// TestVirtualPortCancelSilent tests that reading from a port which doesn't send anything stops when the context is
+// cancelled.
+func TestVirtualPortCancelSilent(t *testing.T) {
+	port, err := NewVirtualPort(nil, time.Second)
+	if err != nil {
+		t.Skip("Pseudo-terminals are unavailable:", err)
+	}
+	defer port.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	connected := make(chan struct{}, 1)
+	config := DSMR4SerialConfig(port.Path)
+	config.StateChanged = func(state ConnectionState, err error) {
+		if state == Connected {
+			connected <- struct{}{}
+		}
+	}
+	rChan := make(chan Readout)
+	errChan := make(chan error)
+	go func() { errChan <- ReadTelegramsContext(ctx, &config, rChan) }()
+
+	select {
+	case <-connected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the port to be opened")
+	}
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-errChan:
+		if err != context.Canceled {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected reading to stop after cancelling")
+	}
+	if _, ok := <-rChan; ok {
+		t.Error("Expected the readout channel to be closed")
+	}
+}
+
+// tiocvhangup is the TIOCVHANGUP ioctl, which hangs up a terminal like unplugging a USB serial adapter does.
+const tiocvhangup = 0x5437
+
+// TestVirtualPortHangup tests that reading from a port which hung up fails, so the port is reopened.
+func TestVirtualPortHangup(t *testing.T) {
+	port, err := NewVirtualPort(NewSimulator(1), 50*time.Millisecond)
+	if err != nil {
+		t.Skip("Pseudo-terminals are unavailable:", err)
+	}
+	defer port.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	states := make(chan error, 10)
+	config := DSMR4SerialConfig(port.Path)
+	config.StateChanged = func(state ConnectionState, err error) {
+		if state == Connected || state == Disconnected {
+			states <- err
+		}
+	}
+	rChan := make(chan Readout)
+	go ReadTelegramsContext(ctx, &config, rChan)
+
+	select {
+	case <-states:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the port to be opened")
+	}
+	<-rChan
+	if err := ioctl(port.slave, tiocvhangup, 0); err != nil {
+		t.Skip("Hanging up the pseudo-terminal is not permitted:", err)
+	}
+
+	select {
+	case err := <-states:
+		if err != errSerialHangup {
+			t.Errorf("Expected the port to be disconnected because it hung up, got %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Error("Expected the port to be disconnected after hanging up")
+	}
+	cancel()
+	for range rChan {
+	}
+}
+
+// TestVirtualPortReadTimeout tests that reading from a port which stays silent for longer than the read timeout fails.
+func TestVirtualPortReadTimeout(t *testing.T) {
+	port, err := NewVirtualPort(nil, time.Second)
+	if err != nil {
+		t.Skip("Pseudo-terminals are unavailable:", err)
+	}
+	defer port.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	disconnected := make(chan error, 10)
+	config := DSMR4SerialConfig(port.Path)
+	config.ReadTimeout = time.Second
+	config.StateChanged = func(state ConnectionState, err error) {
+		if state == Disconnected {
+			disconnected <- err
+		}
+	}
+	rChan := make(chan Readout)
+	go ReadTelegramsContext(ctx, &config, rChan)
+
+	select {
+	case err := <-disconnected:
+		if err != errSerialSilent {
+			t.Errorf("Expected the port to be disconnected because it was silent, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the port to be disconnected after the read timeout")
+	}
+	cancel()
+	for range rChan {
+	}
+}
+
+// TestVirtualPortAutoDetectRemoved tests that auto detecting fails right away when the port is removed.
+func TestVirtualPortAutoDetectRemoved(t *testing.T) {
+	port, err := NewVirtualPort(nil, time.Second)
+	if err != nil {
+		t.Skip("Pseudo-terminals are unavailable:", err)
+	}
+
+	timeout := AutoDetectTimeout
+	AutoDetectTimeout = 10 * time.Second
+	defer func() { AutoDetectTimeout = timeout }()
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		port.Close()
+	}()
+
+	start := time.Now()
+	if _, err := AutoDetectSerialConfig(port.Path).detect(context.Background()); err == nil {
+		t.Error("Expected auto detecting to fail")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected auto detecting to fail right away, took %s", elapsed)
+	}
+}